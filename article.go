@@ -1,13 +1,24 @@
 package service
 
 import (
-	"bytes"
 	"context"
+	"crypto/rand"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
+	"io"
+	"log/slog"
+	"math/big"
+	"mime"
 	"net/http"
-	"sync"
+	"net/url"
+	"regexp"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
 
 	"github.com/gorilla/mux"
 
@@ -20,189 +31,2554 @@ type Article struct {
 	Title   string `json:"title"`
 	Desc    string `json:"description"`
 	Content string `json:"content"`
+	Author  string `json:"author"`
+	Status  string `json:"status"`
+
+	Position int `json:"position"`
+
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// ErrAlreadyExists is returned, wrapped, when creating an article with an id that is taken.
+var ErrAlreadyExists = errors.New("article already exists")
+
+// Version is the build version reported by /debug/info, set at build time with
+// -ldflags "-X example.com/service.Version=...".
+var Version = "dev"
+
+// schemaVersion is the version of the schema created by Prepare, to bump whenever it changes.
+const schemaVersion = 10
+
+// ContentStats are counts computed over the content of an article.
+type ContentStats struct {
+	Words      int `json:"words"`
+	Characters int `json:"characters"`
+}
+
+// Stats counts the words, separated by Unicode white space, and characters of the content of a.
+func (a Article) Stats() ContentStats {
+	return ContentStats{
+		Words:      len(strings.Fields(a.Content)),
+		Characters: utf8.RuneCountInString(a.Content),
+	}
+}
+
+// ErrNotFound is returned, possibly wrapped, when no article matches the given id.
+// Test for it with errors.Is.
+var ErrNotFound = errors.New("article not found")
+
+// articleColumns are the columns read into an Article by scanArticle, in order.
+const articleColumns = `id, title, description, content, author, status, position, updated_at`
+
+// storedColumns are all the columns of the articles tables, articleColumns and those kept apart from Article.
+const storedColumns = articleColumns + `, views`
+
+// viewColumns are the columns of the article_views table, recording each view counted by IncrementViews.
+const viewColumns = `article_id, viewed_at`
+
+// deletionColumns are the columns of the article_deletions table, recording each article deleted or archived, for DeletedBetween.
+const deletionColumns = `id, deleted_at`
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanArticle(sc scanner, a *Article) error {
+	return sc.Scan(&a.ID, &a.Title, &a.Desc, &a.Content, &a.Author, &a.Status, &a.Position, &a.UpdatedAt)
 }
 
 // ArticleService let you store articles.
 // It's the central of our service. It contains all methods we can do with it, and may using external service or storage.
 type ArticleService struct {
 	DB *sql.DB
+
+	tx *sql.Tx
+
+	timeout      time.Duration
+	readTimeout  time.Duration
+	writeTimeout time.Duration
+	deadline     time.Duration
+
+	apiKeys     map[string]Role
+	fieldPolicy FieldPolicy
+	computed    map[string]ComputedField
+
+	log *slog.Logger
+
+	defaults Article
+	limits   *FieldLimits
+
+	newID func() string
+
+	importAllowlist map[string]bool
+	decoders        map[string]Decoder
+
+	readOnly bool
+	hsts     *HSTS
+
+	statementTimeout time.Duration
+	acquireTimeout   time.Duration
+	absoluteURLs     bool
+
+	listPages   PageLimits
+	defaultSort string
+	listMaxAge  time.Duration
+	searchPages PageLimits
+
+	verboseErrors bool
+	jsonNaming    JSONNaming
+
+	slowQuery time.Duration
+	logRate   *float64
+}
+
+// ArticleStore is the set of article operations, implemented by *ArticleService and by the
+// transaction-scoped service passed to WithReadTx and WithTx.
+type ArticleStore interface {
+	Create(ctx context.Context, i Article) (*Article, error)
+	CreateWithID(ctx context.Context, i Article) error
+	Get(ctx context.Context, id string) (*Article, error)
+	GetMany(ctx context.Context, ids []string) ([]Article, error)
+	GetManyAligned(ctx context.Context, ids []string) ([]*Article, error)
+	List(ctx context.Context, opts ...ListOption) ([]Article, error)
+	Query(ctx context.Context, f QueryFilter) ([]Article, error)
+	ListIDs(ctx context.Context, f QueryFilter) ([]string, error)
+	QueryCount(ctx context.Context, f QueryFilter) (int, error)
+	ListByAuthor(ctx context.Context, author string, limit, offset int) ([]Article, error)
+	Search(ctx context.Context, q string, opts ...SearchOption) ([]Article, error)
+	SearchEach(ctx context.Context, q string, fn func(Article) error, opts ...SearchOption) error
+	SearchCount(ctx context.Context, q string, opts ...SearchOption) (int, error)
+	Delete(ctx context.Context, id string) error
+	Touch(ctx context.Context, id string) error
+	IncrementViews(ctx context.Context, id string) (int, error)
+	Trending(ctx context.Context, window time.Duration, limit int) ([]Article, error)
+	Random(ctx context.Context) (*Article, error)
+	Authors(ctx context.Context) ([]string, error)
+	CountByStatus(ctx context.Context) (map[string]int, error)
+	Count(ctx context.Context) (int, error)
+	LastModified(ctx context.Context) (time.Time, error)
+	EstimateCount(ctx context.Context) (n int, exact bool, err error)
+	ChangedBetween(ctx context.Context, from, to time.Time) ([]Article, error)
+	DeletedBetween(ctx context.Context, from, to time.Time) ([]Tombstone, error)
+	ModifiedSince(ctx context.Context, since time.Time) ([]Article, error)
+	DeletedSince(ctx context.Context, since time.Time) ([]Tombstone, error)
+	Reorder(ctx context.Context, orderedIDs []string) error
+	ReplaceAll(ctx context.Context, items []Article) error
+	Neighbors(ctx context.Context, id string) (prev, next *Article, err error)
+	Archive(ctx context.Context, id string) error
+	Unarchive(ctx context.Context, id string) error
+	ImportContentFromURL(ctx context.Context, id, rawURL string) error
+}
+
+// querier is satisfied by both *sql.DB and *sql.Tx.
+type querier interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// db returns the transaction the service is scoped to, or its database, with statements bounded if
+// WithStatementTimeout is set and timed if WithSlowQueryThreshold is.
+func (s *ArticleService) db() querier {
+	var q querier = s.DB
+	if s.tx != nil {
+		q = s.tx
+	} else if s.acquireTimeout > 0 {
+		q = pooledConns{s}
+	}
+	if s.statementTimeout > 0 {
+		q = boundedStatements{q: q, timeout: s.statementTimeout}
+	}
+	if s.slowQuery > 0 {
+		return slowQueryLog{q: q, s: s}
+	}
+	return q
+}
+
+// conn reserves a connection of the pool, waiting for one no longer than the acquire timeout.
+// Past it, the error wraps context.DeadlineExceeded, as when the pool is exhausted.
+func (s *ArticleService) conn(ctx context.Context) (*sql.Conn, error) {
+	actx, cancel := context.WithTimeout(ctx, s.acquireTimeout)
+	defer cancel()
+	c, err := s.DB.Conn(actx)
+	if err != nil {
+		return nil, fmt.Errorf("could not acquire a connection: %w", err)
+	}
+	return c, nil
+}
+
+// pooledConns runs each statement on a connection acquired within the acquire timeout of s,
+// and then bounded only by the context of the statement.
+type pooledConns struct {
+	s *ArticleService
+}
+
+func (p pooledConns) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	c, err := p.s.conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer c.Close()
+	return c.ExecContext(ctx, query, args...)
+}
+
+func (p pooledConns) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	c, err := p.s.conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := c.QueryContext(ctx, query, args...)
+	if err != nil {
+		c.Close()
+		return nil, err
+	}
+	// Close blocks until the rows are closed, then gives the connection back to the pool.
+	go c.Close()
+	return rows, nil
+}
+
+func (p pooledConns) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	actx, cancel := context.WithTimeout(ctx, p.s.acquireTimeout)
+	defer cancel()
+	c, err := p.s.DB.Conn(actx)
+	if err != nil {
+		// A *sql.Row can't be made with an error of ours, so have database/sql fail on the done context.
+		return p.s.DB.QueryRowContext(actx, query, args...)
+	}
+	row := c.QueryRowContext(ctx, query, args...)
+	go c.Close()
+	return row
+}
+
+// boundedStatements runs each statement through q with a context done after timeout, for the driver to cancel
+// the statement then. Rows are read after the query returns, so its context is only released once it is done.
+type boundedStatements struct {
+	q       querier
+	timeout time.Duration
+}
+
+func (b boundedStatements) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	ctx, cancel := context.WithTimeout(ctx, b.timeout)
+	defer cancel()
+	return b.q.ExecContext(ctx, query, args...)
+}
+
+func (b boundedStatements) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	ctx, cancel := context.WithTimeout(ctx, b.timeout)
+	context.AfterFunc(ctx, cancel)
+	return b.q.QueryContext(ctx, query, args...)
+}
+
+func (b boundedStatements) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	ctx, cancel := context.WithTimeout(ctx, b.timeout)
+	context.AfterFunc(ctx, cancel)
+	return b.q.QueryRowContext(ctx, query, args...)
+}
+
+// slowQueryLog warns of statements run through q slower than the slow query threshold of s.
+// Queries are timed until their first row is ready, not until all rows are read.
+type slowQueryLog struct {
+	q querier
+	s *ArticleService
+}
+
+func (l slowQueryLog) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	defer l.time(ctx, query, time.Now())
+	return l.q.ExecContext(ctx, query, args...)
+}
+
+func (l slowQueryLog) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	defer l.time(ctx, query, time.Now())
+	return l.q.QueryContext(ctx, query, args...)
+}
+
+func (l slowQueryLog) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	defer l.time(ctx, query, time.Now())
+	return l.q.QueryRowContext(ctx, query, args...)
+}
+
+// time logs query if it took longer than the threshold since start, with the operation that ran it
+// but never its arguments, which hold article data.
+func (l slowQueryLog) time(ctx context.Context, query string, start time.Time) {
+	d := time.Since(start)
+	if d <= l.s.slowQuery {
+		return
+	}
+	op := "unknown"
+	// Skip time and the querier method to get to the ArticleService method running the statement.
+	if pc, _, _, ok := runtime.Caller(2); ok {
+		if f := runtime.FuncForPC(pc); f != nil {
+			// Such as example.com/service.(*ArticleService).Reorder.func1, in a transaction, which is
+			// logged as (*ArticleService).Reorder.
+			op = f.Name()[strings.LastIndex(f.Name(), "/")+1:]
+			op = op[strings.Index(op, ".")+1:]
+			if i := strings.Index(op, ".func"); i > 0 {
+				op = op[:i]
+			}
+		}
+	}
+	l.s.logger().LogAttrs(ctx, slog.LevelWarn, "slow query",
+		slog.String("op", op),
+		slog.String("query", query),
+		slog.Duration("duration", d),
+	)
+}
+
+// Prepare setup DB schemas
+// Ids are serial, assigned by SQLite to its INTEGER PRIMARY KEY columns and never reused, except with
+// WithIDGenerator, for which they are text.
+func (s *ArticleService) Prepare(ctx context.Context) {
+	// AUTOINCREMENT keeps SQLite from giving out again the ids of deleted or archived articles, which
+	// delta sync clients would take for the articles they were.
+	idType, refType := `INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT`, `BIGINT`
+	if s.newID != nil {
+		idType, refType = `TEXT NOT NULL PRIMARY KEY`, `TEXT`
+	}
+	stats := []string{
+		`CREATE TABLE articles (id ` + idType + `, title TEXT, description TEXT, content TEXT, author TEXT, status TEXT, position INTEGER, updated_at TIMESTAMP, views INTEGER NOT NULL DEFAULT 0);`,
+		`CREATE TABLE articles_archive (id ` + refType + ` NOT NULL PRIMARY KEY, title TEXT, description TEXT, content TEXT, author TEXT, status TEXT, position INTEGER, updated_at TIMESTAMP, views INTEGER NOT NULL DEFAULT 0);`,
+		`CREATE TABLE article_views (article_id ` + refType + ` NOT NULL, viewed_at TIMESTAMP NOT NULL);`,
+		`CREATE TABLE article_deletions (id ` + refType + ` NOT NULL, deleted_at TIMESTAMP NOT NULL);`,
+		`CREATE TABLE schema_migrations (version INTEGER NOT NULL PRIMARY KEY, id_strategy TEXT NOT NULL);`,
+	}
+	if s.DB == nil {
+		panic("no existing database")
+	}
+	for _, stat := range stats {
+		if _, err := s.DB.ExecContext(ctx, stat); err != nil {
+			panic(err)
+		}
+	}
+	if _, err := s.DB.ExecContext(ctx, `INSERT INTO schema_migrations (version, id_strategy) VALUES (?, ?);`, schemaVersion, s.idStrategy()); err != nil {
+		panic(err)
+	}
+}
+
+// idStrategy names how the service makes ids, "serial" or, with WithIDGenerator, "generated".
+// Prepare records it, as the types of the id columns depend on it.
+func (s *ArticleService) idStrategy() string {
+	if s.newID != nil {
+		return "generated"
+	}
+	return "serial"
+}
+
+// appliedIDStrategy reads the id strategy recorded in schema_migrations by Prepare, or "" if none is.
+func (s *ArticleService) appliedIDStrategy(ctx context.Context) (string, error) {
+	stat := `SELECT id_strategy FROM schema_migrations ORDER BY version DESC LIMIT 1;`
+	if s.DB == nil {
+		panic("no existing database")
+	}
+	var strategy string
+	err := s.db().QueryRowContext(ctx, stat).Scan(&strategy)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	}
+	return strategy, err
+}
+
+// ErrSchemaDrift is returned, wrapped, by CheckSchemaDrift when the applied schema is not the one this build expects.
+var ErrSchemaDrift = errors.New("schema drift")
+
+// AppliedSchemaVersion reads the highest schema version recorded in schema_migrations by Prepare.
+func (s *ArticleService) AppliedSchemaVersion(ctx context.Context) (int, error) {
+	stat := `SELECT version FROM schema_migrations ORDER BY version DESC LIMIT 1;`
+	if s.DB == nil {
+		panic("no existing database")
+	}
+	var v int
+	err := s.db().QueryRowContext(ctx, stat).Scan(&v)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, nil
+	}
+	return v, err
+}
+
+// CheckSchemaDrift fails with ErrSchemaDrift if the applied schema version differs from the one this
+// build expects, either because the database is behind or because it was migrated by a newer build,
+// or if the database was prepared for ids made otherwise than the service makes them.
+func (s *ArticleService) CheckSchemaDrift(ctx context.Context) error {
+	applied, err := s.AppliedSchemaVersion(ctx)
+	if err != nil {
+		return err
+	}
+	if applied != schemaVersion {
+		return fmt.Errorf("%w: database schema is at version %d, this build expects %d", ErrSchemaDrift, applied, schemaVersion)
+	}
+	strategy, err := s.appliedIDStrategy(ctx)
+	if err != nil {
+		return err
+	}
+	if strategy != s.idStrategy() {
+		return fmt.Errorf("%w: database schema is for %s ids, the service makes %s ones", ErrSchemaDrift, strategy, s.idStrategy())
+	}
+	return nil
+}
+
+// ErrSchemaMismatch is returned, wrapped, by VerifySchema when the tables differ from what Prepare creates.
+var ErrSchemaMismatch = errors.New("schema mismatch")
+
+// VerifySchema checks that the tables of article service exist with all the columns it reads and writes,
+// naming what is missing, and that they were prepared for the ids the service makes. It reads the columns
+// of an empty result, so it works the same on every driver.
+func (s *ArticleService) VerifySchema(ctx context.Context) error {
+	if s.DB == nil {
+		panic("no existing database")
+	}
+	tables := []struct{ name, columns string }{
+		{"articles", storedColumns},
+		{"articles_archive", storedColumns},
+		{"article_views", viewColumns},
+		{"article_deletions", deletionColumns},
+		{"schema_migrations", "version, id_strategy"},
+	}
+	for _, t := range tables {
+		table := t.name
+		rows, err := s.DB.QueryContext(ctx, `SELECT * FROM `+table+` LIMIT 0;`)
+		if err != nil {
+			return fmt.Errorf("%w: could not read table %s: %v", ErrSchemaMismatch, table, err)
+		}
+		cols, err := rows.Columns()
+		rows.Close()
+		if err != nil {
+			return err
+		}
+		have := make(map[string]bool, len(cols))
+		for _, c := range cols {
+			have[strings.ToLower(c)] = true
+		}
+		var missing []string
+		for _, c := range strings.Split(t.columns, ", ") {
+			if !have[c] {
+				missing = append(missing, c)
+			}
+		}
+		if len(missing) > 0 {
+			return fmt.Errorf("%w: table %s lacks columns %s", ErrSchemaMismatch, table, strings.Join(missing, ", "))
+		}
+	}
+	strategy, err := s.appliedIDStrategy(ctx)
+	if err != nil {
+		return err
+	}
+	if strategy != s.idStrategy() {
+		return fmt.Errorf("%w: tables are for %s ids, the service makes %s ones", ErrSchemaMismatch, strategy, s.idStrategy())
+	}
+	return nil
+}
+
+// Create creates a article, and returns it with its generated id and timestamps.
+// The article is normalized, its fields left empty are filled from the defaults given by WithDefaults,
+// then it is validated against the field limits, failing with a *ValidationError.
+// The id is read from the driver's LastInsertId, or made by the generator given to WithIDGenerator.
+// Articles given no position are placed after all others.
+func (s *ArticleService) Create(ctx context.Context, i Article) (*Article, error) {
+	stat := `INSERT INTO articles (title, description, content, author, status, position, updated_at) VALUES(?,?,?,?,?,?,?);`
+	withIDStat := `INSERT INTO articles (id, title, description, content, author, status, position, updated_at) VALUES(?,?,?,?,?,?,?,?);`
+	if s.DB == nil {
+		panic("no existing database")
+	}
+	i = s.withDefaults(i.Normalize())
+	if err := s.validate(i); err != nil {
+		return nil, err
+	}
+	i.UpdatedAt = time.Now().UTC()
+	if i.Position == 0 {
+		var err error
+		if i.Position, err = s.nextPosition(ctx); err != nil {
+			return nil, err
+		}
+	}
+	if s.newID != nil {
+		i.ID = s.newID()
+		if _, err := s.db().ExecContext(ctx, withIDStat, i.ID, i.Title, i.Desc, i.Content, i.Author, i.Status, i.Position, i.UpdatedAt); err != nil {
+			return nil, err
+		}
+		return &i, nil
+	}
+	res, err := s.db().ExecContext(ctx, stat, i.Title, i.Desc, i.Content, i.Author, i.Status, i.Position, i.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+	i.ID = strconv.FormatInt(id, 10)
+	return &i, nil
+}
+
+// CreateWithID creates article i with the id it carries instead of a generated one, failing with ErrAlreadyExists
+// if that id is taken, archived articles included. Unlike an upsert it never overwrites. Normalization, defaults,
+// validation and positions apply as in Create, and serial ids, without WithIDGenerator, must be integers.
+func (s *ArticleService) CreateWithID(ctx context.Context, i Article) error {
+	stat := `INSERT INTO articles (id, title, description, content, author, status, position, updated_at) VALUES(?,?,?,?,?,?,?,?);`
+	if s.DB == nil {
+		panic("no existing database")
+	}
+	if i.ID == "" {
+		return &ValidationError{Fields: map[string]string{"id": "is required"}}
+	}
+	if _, err := strconv.ParseInt(i.ID, 10, 64); s.newID == nil && err != nil {
+		return &ValidationError{Fields: map[string]string{"id": "must be an integer"}}
+	}
+	i = s.withDefaults(i.Normalize())
+	if err := s.validate(i); err != nil {
+		return err
+	}
+	i.UpdatedAt = time.Now().UTC()
+	// Look for a taken id before inserting, as PostgreSQL aborts the transaction a failed insert is in,
+	// such as the one of ReplaceAll, after which nothing can be read to tell why it failed.
+	if taken, err := s.idTaken(ctx, i.ID); err != nil {
+		return err
+	} else if taken {
+		return fmt.Errorf("%w: id %s", ErrAlreadyExists, i.ID)
+	}
+	if i.Position == 0 {
+		var err error
+		if i.Position, err = s.nextPosition(ctx); err != nil {
+			return err
+		}
+	}
+	_, err := s.db().ExecContext(ctx, stat, i.ID, i.Title, i.Desc, i.Content, i.Author, i.Status, i.Position, i.UpdatedAt)
+	if err == nil || s.tx != nil {
+		return err
+	}
+	// Outside of a transaction, the id may have been taken since it was looked for. Constraint errors
+	// differ between drivers, so tell it apart by looking again.
+	if taken, terr := s.idTaken(ctx, i.ID); terr == nil && taken {
+		return fmt.Errorf("%w: id %s", ErrAlreadyExists, i.ID)
+	}
+	return err
+}
+
+// nextPosition returns the position after that of every article, for an article created without one.
+// Articles created at once may get the same one, which sorting by id then breaks.
+func (s *ArticleService) nextPosition(ctx context.Context) (int, error) {
+	var p int
+	err := s.db().QueryRowContext(ctx, `SELECT COALESCE(MAX(position), 0) + 1 FROM articles;`).Scan(&p)
+	return p, err
+}
+
+// idTaken tells whether an article has id, archived or not, so that unarchiving never meets a new article with it.
+func (s *ArticleService) idTaken(ctx context.Context, id string) (bool, error) {
+	stat := `SELECT (SELECT COUNT(*) FROM articles WHERE id = ?) + (SELECT COUNT(*) FROM articles_archive WHERE id = ?);`
+	var n int
+	err := s.db().QueryRowContext(ctx, stat, id, id).Scan(&n)
+	return n > 0, err
+}
+
+// withDefaults fills the empty fields of i from the configured defaults.
+func (s *ArticleService) withDefaults(i Article) Article {
+	fill := func(v *string, def string) {
+		if *v == "" {
+			*v = def
+		}
+	}
+	fill(&i.Title, s.defaults.Title)
+	fill(&i.Desc, s.defaults.Desc)
+	fill(&i.Content, s.defaults.Content)
+	fill(&i.Author, s.defaults.Author)
+	fill(&i.Status, s.defaults.Status)
+	return i
+}
+
+// validate checks i against the field limits of the service.
+func (s *ArticleService) validate(i Article) error {
+	if s.limits != nil {
+		return i.validate(*s.limits)
+	}
+	return i.Validate()
+}
+
+// Get reads an article.
+// When there is none with id, whatever the backend, the error satisfies errors.Is(err, ErrNotFound);
+// sql.ErrNoRows is mapped to it.
+func (s *ArticleService) Get(ctx context.Context, id string) (*Article, error) {
+	stat := `SELECT ` + articleColumns + ` FROM articles WHERE id = ?;`
+	if s.DB == nil {
+		panic("no existing database")
+	}
+	var article Article
+	err := scanArticle(s.db().QueryRowContext(ctx, stat, id), &article)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%w: id %s", ErrNotFound, id)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &article, nil
+}
+
+// ListOption changes how List reads articles.
+type ListOption func(*listConfig)
+
+type listConfig struct {
+	sort   string
+	limit  int
+	offset int
+}
+
+// SortBy sorts listed articles by one of SortColumns, in descending order if prefixed by "-".
+func SortBy(sort string) ListOption {
+	return func(c *listConfig) {
+		c.sort = sort
+	}
+}
+
+// Page lists at most limit articles, after skipping the first offset ones.
+// Unless sorted otherwise, paged articles are sorted by id so that pages don't overlap.
+func Page(limit, offset int) ListOption {
+	return func(c *listConfig) {
+		c.limit, c.offset = limit, offset
+	}
+}
+
+// SortColumns are the columns articles can be sorted by.
+var SortColumns = []string{"id", "position", "updated_at"}
+
+// ErrInvalidSort is returned, wrapped, when asked to sort by a column not in SortColumns.
+var ErrInvalidSort = errors.New("invalid sort")
+
+// orderBy builds the ORDER BY clause for sort, breaking ties by id so the order is stable.
+func orderBy(sort string) (string, error) {
+	col, dir := sort, "ASC"
+	if strings.HasPrefix(sort, "-") {
+		col, dir = sort[1:], "DESC"
+	}
+	for _, c := range SortColumns {
+		if c != col {
+			continue
+		}
+		if col == "id" {
+			return `ORDER BY id ` + dir, nil
+		}
+		return `ORDER BY ` + col + ` ` + dir + `, id`, nil
+	}
+	return "", fmt.Errorf("%w: %q", ErrInvalidSort, sort)
+}
+
+// maxGetMany is the most ids GET /articles accepts at once.
+const maxGetMany = 100
+
+// GetMany reads the articles of ids in the order of ids, leaving out those that don't exist.
+func (s *ArticleService) GetMany(ctx context.Context, ids []string) ([]Article, error) {
+	aligned, err := s.GetManyAligned(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	ret := make([]Article, 0, len(aligned))
+	for _, a := range aligned {
+		if a != nil {
+			ret = append(ret, *a)
+		}
+	}
+	return ret, nil
+}
+
+// GetManyAligned reads the articles of ids into a slice aligned one-to-one with ids, nil where an
+// article doesn't exist, so that callers such as data loaders can map results by index.
+func (s *ArticleService) GetManyAligned(ctx context.Context, ids []string) ([]*Article, error) {
+	ret := make([]*Article, len(ids))
+	if len(ids) == 0 {
+		return ret, nil
+	}
+	args := make([]interface{}, len(ids))
+	for i, id := range ids {
+		args[i] = id
+	}
+	stat := `SELECT ` + articleColumns + ` FROM articles WHERE id IN (?` + strings.Repeat(`,?`, len(ids)-1) + `);`
+	if s.DB == nil {
+		panic("no existing database")
+	}
+	rows, err := s.db().QueryContext(ctx, stat, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	found := make(map[string]*Article, len(ids))
+	for rows.Next() {
+		var article Article
+		if err := scanArticle(rows, &article); err != nil {
+			return nil, err
+		}
+		found[article.ID] = &article
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	for i, id := range ids {
+		ret[i] = found[id]
+	}
+	return ret, nil
 }
 
-// Prepare setup DB schemas
-func (s ArticleService) Prepare(ctx context.Context) {
-	stat := `CREATE TABLE articles (id BIGSERIAL NOT NULL PRIMARY KEY, title TEXT, description TEXT, content TEXT);`
-	if s.DB == nil {
-		panic("no existing database")
-	}
-	if _, err := s.DB.ExecContext(ctx, stat); err != nil {
-		panic(err)
-	}
-}
+// List reads all articles
+func (s *ArticleService) List(ctx context.Context, opts ...ListOption) ([]Article, error) {
+	var c listConfig
+	for _, opt := range opts {
+		opt(&c)
+	}
+	stat := `SELECT ` + articleColumns + ` FROM articles;`
+	if c.sort == "" && c.limit > 0 {
+		c.sort = "id"
+	}
+	var args []interface{}
+	if c.sort != "" {
+		order, err := orderBy(c.sort)
+		if err != nil {
+			return nil, err
+		}
+		if c.limit > 0 {
+			order += ` LIMIT ? OFFSET ?`
+			args = append(args, c.limit, c.offset)
+		}
+		stat = `SELECT ` + articleColumns + ` FROM articles ` + order + `;`
+	}
+	if s.DB == nil {
+		panic("no existing database")
+	}
+	rows, err := s.db().QueryContext(ctx, stat, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ret := make([]Article, 0, 20)
+	for rows.Next() {
+		var article Article
+		if err := scanArticle(rows, &article); err != nil {
+			return nil, err
+		}
+		ret = append(ret, article)
+	}
+	return ret, rows.Err()
+}
+
+// SearchOption changes how Search and SearchCount match articles.
+type SearchOption func(*searchConfig)
+
+type searchConfig struct {
+	ignoreCase bool
+	mode       SearchMode
+	limit      int
+	offset     int
+	excluded   []string
+}
+
+func newSearchConfig(opts []SearchOption) searchConfig {
+	var c searchConfig
+	for _, opt := range opts {
+		opt(&c)
+	}
+	return c
+}
+
+// IgnoreCase matches regardless of letter case, whatever the collation of the backend.
+func IgnoreCase() SearchOption {
+	return func(c *searchConfig) {
+		c.ignoreCase = true
+	}
+}
+
+// SearchMode is how the query of Search is matched, set with Match.
+type SearchMode string
+
+// Search modes, MatchSubstring being the default.
+const (
+	// MatchSubstring matches articles with a field containing the query as is, % and _ included.
+	MatchSubstring SearchMode = "substring"
+	// MatchPhrase matches articles with a field containing the words of the query in sequence, apart by any
+	// whitespace, with neither a letter nor a digit right before or after them.
+	MatchPhrase SearchMode = "phrase"
+	// MatchWords matches articles containing every word of the query, each as a substring of any field.
+	MatchWords SearchMode = "words"
+)
+
+// Match has Search match its query in mode, or as MatchSubstring if mode is none of the above.
+func Match(mode SearchMode) SearchOption {
+	return func(c *searchConfig) {
+		c.mode = mode
+	}
+}
+
+// SearchPage has Search read at most limit matching articles in id order, after skipping the first offset ones.
+// SearchCount still counts every match.
+func SearchPage(limit, offset int) SearchOption {
+	return func(c *searchConfig) {
+		c.limit, c.offset = limit, offset
+	}
+}
+
+// ExcludeFields has Search ignore fields, such as those a caller must not see, among title, description and content.
+// Nothing matches once all three are excluded.
+func ExcludeFields(fields ...string) SearchOption {
+	return func(c *searchConfig) {
+		c.excluded = append(c.excluded, fields...)
+	}
+}
+
+// searchableFields are the columns searched for text, by json name.
+var searchableFields = []string{"title", "description", "content"}
+
+// textFields returns the searchableFields not in excluded.
+func textFields(excluded []string) []string {
+	var fields []string
+	for _, f := range searchableFields {
+		keep := true
+		for _, e := range excluded {
+			keep = keep && f != e
+		}
+		if keep {
+			fields = append(fields, f)
+		}
+	}
+	return fields
+}
+
+// searchFilter builds the WHERE clause shared by Search and SearchCount, so a count always matches its results.
+// It ANDs groups of patterns, each group matching if any field is LIKE any of its patterns.
+// LIKE can't tell where words end, so for MatchPhrase the clause only selects the articles having every word,
+// and the articles selected must also pass match, which is nil for other modes.
+func searchFilter(q string, opts []SearchOption) (where string, args []interface{}, match func(Article) bool) {
+	c := newSearchConfig(opts)
+	var groups [][]string
+	switch c.mode {
+	case MatchPhrase, MatchWords:
+		for _, word := range strings.Fields(q) {
+			groups = append(groups, []string{"%" + escapeLike(word) + "%"})
+		}
+	default:
+		groups = [][]string{{"%" + escapeLike(q) + "%"}}
+	}
+	if len(groups) == 0 {
+		return "", nil, nil
+	}
+	fields := textFields(c.excluded)
+	if len(fields) == 0 {
+		return `WHERE 1 = 0`, nil, nil
+	}
+	if c.mode == MatchPhrase {
+		match = phraseMatcher(strings.Fields(q), fields, c.ignoreCase)
+	}
+	var conds []string
+	for _, patterns := range groups {
+		var alts []string
+		for _, field := range fields {
+			for _, p := range patterns {
+				if c.ignoreCase {
+					alts = append(alts, `LOWER(`+field+`) LIKE LOWER(?) ESCAPE '!'`)
+				} else {
+					alts = append(alts, field+` LIKE ? ESCAPE '!'`)
+				}
+				args = append(args, p)
+			}
+		}
+		conds = append(conds, `(`+strings.Join(alts, ` OR `)+`)`)
+	}
+	return `WHERE ` + strings.Join(conds, ` AND `), args, match
+}
+
+// phraseMatcher returns whether any of fields of an article has words in sequence, apart by any whitespace,
+// with neither a letter nor a digit right before or after them.
+func phraseMatcher(words, fields []string, ignoreCase bool) func(Article) bool {
+	quoted := make([]string, len(words))
+	for i, w := range words {
+		quoted[i] = regexp.QuoteMeta(w)
+	}
+	expr := `(?:^|[^\pL\pN])` + strings.Join(quoted, `\s+`) + `(?:$|[^\pL\pN])`
+	if ignoreCase {
+		expr = `(?i)` + expr
+	}
+	re := regexp.MustCompile(expr)
+	return func(a Article) bool {
+		text := map[string]string{"title": a.Title, "description": a.Desc, "content": a.Content}
+		for _, f := range fields {
+			if re.MatchString(text[f]) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// escapeLike escapes the LIKE wildcards of s, and the escape character itself, for a LIKE ... ESCAPE '!'.
+func escapeLike(s string) string {
+	return strings.NewReplacer("!", "!!", "%", "!%", "_", "!_").Replace(s)
+}
+
+// Search reads articles whose title, description or content contains q
+func (s *ArticleService) Search(ctx context.Context, q string, opts ...SearchOption) ([]Article, error) {
+	ret := make([]Article, 0, 20)
+	err := s.SearchEach(ctx, q, func(a Article) error {
+		ret = append(ret, a)
+		return nil
+	}, opts...)
+	return ret, err
+}
+
+// SearchEach calls fn with each article matched by Search as it is read, without buffering them.
+// It stops at the first error from fn or in reading articles, or once ctx is done.
+func (s *ArticleService) SearchEach(ctx context.Context, q string, fn func(Article) error, opts ...SearchOption) error {
+	where, args, match := searchFilter(q, opts)
+	stat := `SELECT ` + articleColumns + ` FROM articles ` + where + `;`
+	// Articles failing match are only left out once read, so their pages are skipped and limited here.
+	var skip, left int
+	switch c := newSearchConfig(opts); {
+	case c.limit > 0 && match == nil:
+		stat = `SELECT ` + articleColumns + ` FROM articles ` + where + ` ORDER BY id LIMIT ? OFFSET ?;`
+		args = append(args, c.limit, c.offset)
+	case c.limit > 0:
+		stat = `SELECT ` + articleColumns + ` FROM articles ` + where + ` ORDER BY id;`
+		skip, left = c.offset, c.limit
+	}
+	if s.DB == nil {
+		panic("no existing database")
+	}
+	rows, err := s.db().QueryContext(ctx, stat, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		var article Article
+		if err := scanArticle(rows, &article); err != nil {
+			return err
+		}
+		if match != nil && !match(article) {
+			continue
+		}
+		if skip > 0 {
+			skip--
+			continue
+		}
+		if err := fn(article); err != nil {
+			return err
+		}
+		if left--; left == 0 {
+			return nil
+		}
+	}
+	return rows.Err()
+}
+
+// SearchCount counts articles matched by Search
+func (s *ArticleService) SearchCount(ctx context.Context, q string, opts ...SearchOption) (int, error) {
+	where, args, match := searchFilter(q, opts)
+	stat := `SELECT COUNT(*) FROM articles ` + where + `;`
+	if s.DB == nil {
+		panic("no existing database")
+	}
+	if match != nil {
+		n := 0
+		err := s.SearchEach(ctx, q, func(Article) error {
+			n++
+			return nil
+		}, append(opts, SearchPage(0, 0))...)
+		return n, err
+	}
+	var n int
+	err := s.db().QueryRowContext(ctx, stat, args...).Scan(&n)
+	return n, err
+}
+
+// Delete deletes an article, failing with ErrNotFound if there is none with id.
+// The deletion is recorded in the same transaction, for DeletedBetween.
+func (s *ArticleService) Delete(ctx context.Context, id string) error {
+	stat := `DELETE FROM articles WHERE id = ?;`
+	tombstoneStat := `INSERT INTO article_deletions (` + deletionColumns + `) VALUES (?,?);`
+	if s.DB == nil {
+		panic("no existing database")
+	}
+	return s.inTx(ctx, nil, func(ts *ArticleService) error {
+		res, err := ts.db().ExecContext(ctx, stat, id)
+		if err != nil {
+			return err
+		}
+		n, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			return fmt.Errorf("%w: id %s", ErrNotFound, id)
+		}
+		_, err = ts.db().ExecContext(ctx, tombstoneStat, id, time.Now().UTC())
+		return err
+	})
+}
+
+// Touch bumps the updated_at of an article without changing anything else
+func (s *ArticleService) Touch(ctx context.Context, id string) error {
+	stat := `UPDATE articles SET updated_at = ? WHERE id = ?;`
+	if s.DB == nil {
+		panic("no existing database")
+	}
+	res, err := s.db().ExecContext(ctx, stat, time.Now().UTC(), id)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// IncrementViews adds one to the view count of article id and returns the new count, recording
+// the view in article_views for Trending. The count is read back within the transaction of the update, whose row lock keeps concurrent
+// increments from interleaving, so none is lost and each caller gets its own count.
+func (s *ArticleService) IncrementViews(ctx context.Context, id string) (int, error) {
+	updateStat := `UPDATE articles SET views = views + 1 WHERE id = ?;`
+	selectStat := `SELECT views FROM articles WHERE id = ?;`
+	eventStat := `INSERT INTO article_views (` + viewColumns + `) VALUES (?,?);`
+	if s.DB == nil {
+		panic("no existing database")
+	}
+	var views int
+	err := s.inTx(ctx, nil, func(ts *ArticleService) error {
+		res, err := ts.db().ExecContext(ctx, updateStat, id)
+		if err != nil {
+			return err
+		}
+		n, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			return fmt.Errorf("%w: id %s", ErrNotFound, id)
+		}
+		if _, err := ts.db().ExecContext(ctx, eventStat, id, time.Now().UTC()); err != nil {
+			return err
+		}
+		return ts.db().QueryRowContext(ctx, selectStat, id).Scan(&views)
+	})
+	return views, err
+}
+
+// Trending reads up to limit articles, most viewed first, counting only the views recorded by
+// IncrementViews within window from now. Articles not viewed within it are left out.
+func (s *ArticleService) Trending(ctx context.Context, window time.Duration, limit int) ([]Article, error) {
+	stat := `SELECT ` + articleColumns + ` FROM articles JOIN (SELECT article_id, COUNT(*) AS recent FROM article_views WHERE viewed_at >= ? GROUP BY article_id) v ON v.article_id = articles.id ORDER BY v.recent DESC, id LIMIT ?;`
+	if s.DB == nil {
+		panic("no existing database")
+	}
+	rows, err := s.db().QueryContext(ctx, stat, time.Now().UTC().Add(-window), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ret := make([]Article, 0, limit)
+	for rows.Next() {
+		var article Article
+		if err := scanArticle(rows, &article); err != nil {
+			return nil, err
+		}
+		ret = append(ret, article)
+	}
+	return ret, rows.Err()
+}
+
+// Random reads an article picked at random, or ErrNotFound if there is none.
+// It counts the articles and reads the one at a random offset within one read-only transaction,
+// which costs a COUNT(*) and a short scan rather than sorting the whole table by a random key.
+func (s *ArticleService) Random(ctx context.Context) (*Article, error) {
+	stat := `SELECT ` + articleColumns + ` FROM articles ORDER BY id LIMIT 1 OFFSET ?;`
+	if s.DB == nil {
+		panic("no existing database")
+	}
+	var article Article
+	err := s.inTx(ctx, &sql.TxOptions{ReadOnly: true}, func(ts *ArticleService) error {
+		var n int64
+		if err := ts.db().QueryRowContext(ctx, `SELECT COUNT(*) FROM articles;`).Scan(&n); err != nil {
+			return err
+		}
+		if n == 0 {
+			return ErrNotFound
+		}
+		offset, err := rand.Int(rand.Reader, big.NewInt(n))
+		if err != nil {
+			return err
+		}
+		err = scanArticle(ts.db().QueryRowContext(ctx, stat, offset.Int64()), &article)
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrNotFound
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &article, nil
+}
+
+// Reorder gives the articles of orderedIDs their position in it, starting at 1, in one transaction. The other
+// articles follow them, in the order they were in, so that positions stay distinct. Articles whose position
+// changes have their updated_at bumped. It fails with ErrNotFound, changing nothing, if an id matches no article.
+func (s *ArticleService) Reorder(ctx context.Context, orderedIDs []string) error {
+	readStat := `SELECT id, position FROM articles ORDER BY position, id;`
+	stat := `UPDATE articles SET position = ?, updated_at = ? WHERE id = ?;`
+	if s.DB == nil {
+		panic("no existing database")
+	}
+	return s.inTx(ctx, nil, func(ts *ArticleService) error {
+		rows, err := ts.db().QueryContext(ctx, readStat)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		positions := make(map[string]int)
+		var rest []string
+		for rows.Next() {
+			var id string
+			var p int
+			if err := rows.Scan(&id, &p); err != nil {
+				return err
+			}
+			positions[id] = p
+			rest = append(rest, id)
+		}
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		// Done reading before writing on the same connection.
+		rows.Close()
+
+		listed := make(map[string]bool, len(orderedIDs))
+		order := make([]string, 0, len(rest))
+		for _, id := range orderedIDs {
+			if _, ok := positions[id]; !ok {
+				return fmt.Errorf("%w: id %s", ErrNotFound, id)
+			}
+			if !listed[id] {
+				listed[id] = true
+				order = append(order, id)
+			}
+		}
+		for _, id := range rest {
+			if !listed[id] {
+				order = append(order, id)
+			}
+		}
+		now := time.Now().UTC()
+		for i, id := range order {
+			if positions[id] == i+1 {
+				continue
+			}
+			if _, err := ts.db().ExecContext(ctx, stat, i+1, now, id); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// ReplaceAll replaces every article by items in a single transaction, so readers see either the old
+// articles or the new ones and a failure leaves the old ones in place. Items carrying an id keep it,
+// others get a generated one. Fields of a ValidationError are prefixed by the index of the item, as in "2.title".
+// Archived articles are left alone, so an item with the id of one fails with ErrAlreadyExists, as in CreateWithID.
+// The articles replaced whose id is not in items afterwards are recorded as deleted, for DeletedBetween,
+// and the views counted for the articles replaced are dropped.
+func (s *ArticleService) ReplaceAll(ctx context.Context, items []Article) error {
+	tombstoneStat := `INSERT INTO article_deletions (` + deletionColumns + `) VALUES (?,?);`
+	stat := `DELETE FROM articles;`
+	viewsStat := `DELETE FROM article_views;`
+	if s.DB == nil {
+		panic("no existing database")
+	}
+	return s.inTx(ctx, nil, func(ts *ArticleService) error {
+		ids, err := ts.ListIDs(ctx, QueryFilter{})
+		if err != nil {
+			return err
+		}
+		if _, err := ts.db().ExecContext(ctx, stat); err != nil {
+			return err
+		}
+		if _, err := ts.db().ExecContext(ctx, viewsStat); err != nil {
+			return err
+		}
+		// Store the items carrying an id first, so that none is taken by a generated one.
+		order := make([]int, 0, len(items))
+		for i, item := range items {
+			if item.ID != "" {
+				order = append(order, i)
+			}
+		}
+		for i, item := range items {
+			if item.ID == "" {
+				order = append(order, i)
+			}
+		}
+		kept := make(map[string]bool, len(items))
+		for _, i := range order {
+			item := items[i]
+			var err error
+			if item.ID != "" {
+				err = ts.CreateWithID(ctx, item)
+			} else {
+				var created *Article
+				if created, err = ts.Create(ctx, item); err == nil {
+					item.ID = created.ID
+				}
+			}
+			var invalid *ValidationError
+			if errors.As(err, &invalid) {
+				fields := make(map[string]string, len(invalid.Fields))
+				for f, msg := range invalid.Fields {
+					fields[strconv.Itoa(i)+"."+f] = msg
+				}
+				return &ValidationError{Fields: fields}
+			}
+			if err != nil {
+				return fmt.Errorf("article %d: %w", i, err)
+			}
+			kept[item.ID] = true
+		}
+		// Ids are only known once items are stored, as generated ones may reuse those just deleted.
+		now := time.Now().UTC()
+		for _, id := range ids {
+			if kept[id] {
+				continue
+			}
+			if _, err := ts.db().ExecContext(ctx, tombstoneStat, id, now); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Neighbors reads the articles right before and after article id in id order, nil at either end.
+// It fails with ErrNotFound if there is no article id.
+func (s *ArticleService) Neighbors(ctx context.Context, id string) (prev, next *Article, err error) {
+	prevStat := `SELECT ` + articleColumns + ` FROM articles WHERE id < ? ORDER BY id DESC LIMIT 1;`
+	nextStat := `SELECT ` + articleColumns + ` FROM articles WHERE id > ? ORDER BY id ASC LIMIT 1;`
+	if s.DB == nil {
+		panic("no existing database")
+	}
+	neighbor := func(ts *ArticleService, stat string) (*Article, error) {
+		var a Article
+		err := scanArticle(ts.db().QueryRowContext(ctx, stat, id), &a)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		return &a, nil
+	}
+	err = s.inTx(ctx, &sql.TxOptions{ReadOnly: true}, func(ts *ArticleService) error {
+		if _, err := ts.Get(ctx, id); err != nil {
+			return err
+		}
+		if prev, err = neighbor(ts, prevStat); err != nil {
+			return err
+		}
+		next, err = neighbor(ts, nextStat)
+		return err
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return prev, next, nil
+}
+
+// Archive moves article id to the articles_archive table, out of reach of every other method, in one transaction.
+// It is recorded as deleted, for delta sync clients to drop it. It fails with ErrNotFound if there is no such article.
+func (s *ArticleService) Archive(ctx context.Context, id string) error {
+	tombstoneStat := `INSERT INTO article_deletions (` + deletionColumns + `) VALUES (?,?);`
+	if s.DB == nil {
+		panic("no existing database")
+	}
+	return s.inTx(ctx, nil, func(ts *ArticleService) error {
+		if err := ts.moveArticle(ctx, id, "articles", "articles_archive"); err != nil {
+			return err
+		}
+		_, err := ts.db().ExecContext(ctx, tombstoneStat, id, time.Now().UTC())
+		return err
+	})
+}
+
+// Unarchive moves article id back from the articles_archive table, in one transaction. The record of its archiving
+// is dropped, so that delta sync does not report it both changed and deleted since before it was archived.
+// It fails with ErrNotFound if there is no such archived article, and with ErrAlreadyExists if another article has its id.
+func (s *ArticleService) Unarchive(ctx context.Context, id string) error {
+	tombstoneStat := `DELETE FROM article_deletions WHERE id = ?;`
+	if s.DB == nil {
+		panic("no existing database")
+	}
+	return s.inTx(ctx, nil, func(ts *ArticleService) error {
+		if err := ts.moveArticle(ctx, id, "articles_archive", "articles"); err != nil {
+			return err
+		}
+		_, err := ts.db().ExecContext(ctx, tombstoneStat, id)
+		return err
+	})
+}
+
+// moveArticle moves the row of article id from table from to table to, which share their columns,
+// bumping its updated_at. It fails with ErrAlreadyExists if table to has a row with id already.
+func (s *ArticleService) moveArticle(ctx context.Context, id, from, to string) error {
+	countStat := `SELECT (SELECT COUNT(*) FROM ` + from + ` WHERE id = ?), (SELECT COUNT(*) FROM ` + to + ` WHERE id = ?);`
+	copyStat := `INSERT INTO ` + to + ` (` + storedColumns + `) SELECT ` + strings.Replace(storedColumns, `updated_at`, `?`, 1) +
+		` FROM ` + from + ` WHERE id = ?;`
+	deleteStat := `DELETE FROM ` + from + ` WHERE id = ?;`
+	if s.DB == nil {
+		panic("no existing database")
+	}
+	return s.inTx(ctx, nil, func(ts *ArticleService) error {
+		// Looked for before copying, as a failed insert would abort the transaction on PostgreSQL.
+		var found, taken int
+		if err := ts.db().QueryRowContext(ctx, countStat, id, id).Scan(&found, &taken); err != nil {
+			return err
+		}
+		if found == 0 {
+			return fmt.Errorf("%w: id %s", ErrNotFound, id)
+		}
+		if taken > 0 {
+			return fmt.Errorf("%w: id %s", ErrAlreadyExists, id)
+		}
+		if _, err := ts.db().ExecContext(ctx, copyStat, time.Now().UTC(), id); err != nil {
+			return err
+		}
+		_, err := ts.db().ExecContext(ctx, deleteStat, id)
+		return err
+	})
+}
+
+// Authors reads the distinct authors of articles, sorted and without empty ones
+func (s *ArticleService) Authors(ctx context.Context) ([]string, error) {
+	stat := `SELECT DISTINCT author FROM articles WHERE author IS NOT NULL AND author <> '' ORDER BY author;`
+	if s.DB == nil {
+		panic("no existing database")
+	}
+	rows, err := s.db().QueryContext(ctx, stat)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ret := make([]string, 0, 20)
+	for rows.Next() {
+		var author string
+		if err := rows.Scan(&author); err != nil {
+			return nil, err
+		}
+		ret = append(ret, author)
+	}
+	return ret, rows.Err()
+}
+
+// LastModified reads when articles last changed, or the zero time if they never did: the newest updated_at of
+// all articles, archived ones included, or the time of the newest deletion if later.
+func (s *ArticleService) LastModified(ctx context.Context) (time.Time, error) {
+	stats := []string{
+		`SELECT updated_at FROM articles WHERE updated_at IS NOT NULL ORDER BY updated_at DESC LIMIT 1;`,
+		`SELECT updated_at FROM articles_archive WHERE updated_at IS NOT NULL ORDER BY updated_at DESC LIMIT 1;`,
+		`SELECT deleted_at FROM article_deletions ORDER BY deleted_at DESC LIMIT 1;`,
+	}
+	if s.DB == nil {
+		panic("no existing database")
+	}
+	var last time.Time
+	for _, stat := range stats {
+		var t time.Time
+		err := s.db().QueryRowContext(ctx, stat).Scan(&t)
+		if errors.Is(err, sql.ErrNoRows) {
+			continue
+		}
+		if err != nil {
+			return time.Time{}, err
+		}
+		if t.After(last) {
+			last = t
+		}
+	}
+	return last, nil
+}
+
+// Stats reports the state of the connection pool of the database.
+func (s *ArticleService) Stats() sql.DBStats {
+	if s.DB == nil {
+		panic("no existing database")
+	}
+	return s.DB.Stats()
+}
+
+// Count counts all articles exactly.
+func (s *ArticleService) Count(ctx context.Context) (int, error) {
+	stat := `SELECT COUNT(*) FROM articles;`
+	if s.DB == nil {
+		panic("no existing database")
+	}
+	var n int
+	err := s.db().QueryRowContext(ctx, stat).Scan(&n)
+	return n, err
+}
+
+// EstimateCount returns the number of articles from the statistics of the backend, which is cheap on large
+// tables but only as accurate as the last ANALYZE, so it may be off by the rows changed since.
+// Statistics are read from pg_class on PostgreSQL. Elsewhere, inside a transaction or before the table
+// was ever analyzed, it falls back to Count, and exact is true.
+func (s *ArticleService) EstimateCount(ctx context.Context) (n int, exact bool, err error) {
+	stat := `SELECT CAST(reltuples AS BIGINT) FROM pg_class WHERE relname = 'articles';`
+	if s.DB == nil {
+		panic("no existing database")
+	}
+	// A failed statement would abort the transaction on PostgreSQL, so don't try within one.
+	if s.tx == nil {
+		var estimate int64
+		if err := s.DB.QueryRowContext(ctx, stat).Scan(&estimate); err == nil && estimate > 0 {
+			return int(estimate), false, nil
+		}
+	}
+	n, err = s.Count(ctx)
+	return n, true, err
+}
+
+// KnownStatuses are the statuses CountByStatus always reports, even when no article has them.
+// Articles may have other statuses, which are counted too.
+var KnownStatuses = []string{"draft", "published"}
+
+// CountByStatus counts articles per status, with every one of KnownStatuses present and
+// articles without a status counted under "".
+func (s *ArticleService) CountByStatus(ctx context.Context) (map[string]int, error) {
+	stat := `SELECT COALESCE(status, ''), COUNT(*) FROM articles GROUP BY COALESCE(status, '');`
+	if s.DB == nil {
+		panic("no existing database")
+	}
+	rows, err := s.db().QueryContext(ctx, stat)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ret := make(map[string]int, len(KnownStatuses))
+	for _, status := range KnownStatuses {
+		ret[status] = 0
+	}
+	for rows.Next() {
+		var status string
+		var n int
+		if err := rows.Scan(&status, &n); err != nil {
+			return nil, err
+		}
+		ret[status] = n
+	}
+	return ret, rows.Err()
+}
+
+// ModifiedSince reads the articles created or updated after since, oldest change first, for delta sync.
+// Deleted articles are gone from the table, so deletions are not reported; DeletedSince reads them.
+func (s *ArticleService) ModifiedSince(ctx context.Context, since time.Time) ([]Article, error) {
+	stat := `SELECT ` + articleColumns + ` FROM articles WHERE updated_at > ? ORDER BY updated_at, id;`
+	if s.DB == nil {
+		panic("no existing database")
+	}
+	rows, err := s.db().QueryContext(ctx, stat, since.UTC())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ret := make([]Article, 0, 20)
+	for rows.Next() {
+		var article Article
+		if err := scanArticle(rows, &article); err != nil {
+			return nil, err
+		}
+		ret = append(ret, article)
+	}
+	return ret, rows.Err()
+}
+
+// ChangedBetween reads the articles created or updated from from to to, both included, oldest change first,
+// for incremental export. Those deleted since are left out, and read by DeletedBetween.
+func (s *ArticleService) ChangedBetween(ctx context.Context, from, to time.Time) ([]Article, error) {
+	stat := `SELECT ` + articleColumns + ` FROM articles WHERE updated_at BETWEEN ? AND ? ORDER BY updated_at, id;`
+	if s.DB == nil {
+		panic("no existing database")
+	}
+	rows, err := s.db().QueryContext(ctx, stat, from.UTC(), to.UTC())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ret := make([]Article, 0, 20)
+	for rows.Next() {
+		var article Article
+		if err := scanArticle(rows, &article); err != nil {
+			return nil, err
+		}
+		ret = append(ret, article)
+	}
+	return ret, rows.Err()
+}
+
+// Tombstone records that an article was deleted, by Delete or ReplaceAll.
+type Tombstone struct {
+	ID        string    `json:"id"`
+	DeletedAt time.Time `json:"deleted_at"`
+}
+
+// DeletedBetween reads the deletions and archivings made from from to to, both included, oldest first.
+func (s *ArticleService) DeletedBetween(ctx context.Context, from, to time.Time) ([]Tombstone, error) {
+	stat := `SELECT ` + deletionColumns + ` FROM article_deletions WHERE deleted_at BETWEEN ? AND ? ORDER BY deleted_at, id;`
+	if s.DB == nil {
+		panic("no existing database")
+	}
+	rows, err := s.db().QueryContext(ctx, stat, from.UTC(), to.UTC())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ret := make([]Tombstone, 0, 20)
+	for rows.Next() {
+		var t Tombstone
+		if err := rows.Scan(&t.ID, &t.DeletedAt); err != nil {
+			return nil, err
+		}
+		ret = append(ret, t)
+	}
+	return ret, rows.Err()
+}
+
+// DeletedSince reads the deletions made after since, oldest first, for delta sync along with ModifiedSince.
+func (s *ArticleService) DeletedSince(ctx context.Context, since time.Time) ([]Tombstone, error) {
+	stat := `SELECT ` + deletionColumns + ` FROM article_deletions WHERE deleted_at > ? ORDER BY deleted_at, id;`
+	if s.DB == nil {
+		panic("no existing database")
+	}
+	rows, err := s.db().QueryContext(ctx, stat, since.UTC())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ret := make([]Tombstone, 0, 20)
+	for rows.Next() {
+		var t Tombstone
+		if err := rows.Scan(&t.ID, &t.DeletedAt); err != nil {
+			return nil, err
+		}
+		ret = append(ret, t)
+	}
+	return ret, rows.Err()
+}
+
+// WithReadTx runs fn against a store scoped to a read-only transaction, so its reads see one consistent snapshot.
+// Calls made on a service already scoped to a transaction reuse it.
+func (s *ArticleService) WithReadTx(ctx context.Context, fn func(ArticleStore) error) error {
+	return s.inTx(ctx, &sql.TxOptions{ReadOnly: true}, func(ts *ArticleService) error { return fn(ts) })
+}
+
+// WithTx runs fn against a store scoped to a transaction, committing it when fn returns nil and rolling it back otherwise.
+// Calls made on a service already scoped to a transaction reuse it.
+func (s *ArticleService) WithTx(ctx context.Context, fn func(ArticleStore) error) error {
+	return s.inTx(ctx, nil, func(ts *ArticleService) error { return fn(ts) })
+}
+
+// errReadOnlyUnsupported is what database/sql answers, without an error value to match, when asked a
+// read-only transaction of a driver that can't begin one.
+const errReadOnlyUnsupported = "sql: driver does not support read-only transactions"
+
+func (s *ArticleService) inTx(ctx context.Context, opts *sql.TxOptions, fn func(*ArticleService) error) error {
+	if s.tx != nil {
+		return fn(s)
+	}
+	if s.DB == nil {
+		panic("no existing database")
+	}
+	begin := s.DB.BeginTx
+	if s.acquireTimeout > 0 {
+		c, err := s.conn(ctx)
+		if err != nil {
+			return err
+		}
+		defer c.Close()
+		begin = c.BeginTx
+	}
+	tx, err := begin(ctx, opts)
+	if err != nil && opts != nil && opts.ReadOnly && err.Error() == errReadOnlyUnsupported {
+		// Drivers such as ramsql have no read-only transactions; fall back to a plain one.
+		plain := *opts
+		plain.ReadOnly = false
+		tx, err = begin(ctx, &plain)
+	}
+	if err != nil {
+		return err
+	}
+	// Scope a copy, so that the service itself, shared by handlers, stays on the database.
+	ts := *s
+	ts.tx = tx
+	if err := fn(&ts); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// RESTful returns RESTful API of article service.
+// It contains its routes and handle http requests.
+func (s *ArticleService) RESTful() http.Handler {
+	m := mux.NewRouter().StrictSlash(false)
+	m.NotFoundHandler = serverHeaders(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeError(w, http.StatusNotFound, "no such route")
+	}))
+	m.MethodNotAllowedHandler = serverHeaders(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}))
+	s.RegisterRoutes(m)
+	return trimTrailingSlash(m)
+}
+
+// RegisterRoutes attaches the routes of article service to r, which may already have routes of its own or be a subrouter.
+// Unlike RESTful, it leaves the trailing slash policy and the handling of unmatched routes to the owner of r.
+func (s *ArticleService) RegisterRoutes(r *mux.Router) {
+	m := r.NewRoute().Subrouter()
+	// logAccess wraps enforceDeadline, so that requests cut short are logged with the 503 they got.
+	m.Use(serverHeaders, noStoreWrites, s.enforceHTTPS, s.authenticate, s.logAccess, s.enforceDeadline, s.rejectWrites, s.withTimeout)
+
+	m.Handle("/list", methodDispatcher{http.MethodGet: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		f, err := s.queryFilter(r)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		// Any change to any article may change the list, so it is as fresh as the last change.
+		modified, err := s.LastModified(r.Context())
+		if err != nil {
+			s.writeStoreError(w, err, "could not read data")
+			return
+		}
+		if s.listMaxAge > 0 {
+			w.Header().Set("Cache-Control", "max-age="+strconv.Itoa(int(s.listMaxAge/time.Second)))
+			// What the list holds depends on the role of the key, so caches must not serve one key's to another.
+			if len(s.apiKeys) > 0 || len(s.fieldPolicy) > 0 {
+				w.Header().Add("Vary", "X-API-Key")
+			}
+		}
+		if !modified.IsZero() {
+			// HTTP dates are in whole seconds, so round up, and only validate with seconds that are over:
+			// another change within the current one would otherwise go unnoticed.
+			lm := modified.Truncate(time.Second)
+			if lm.Before(modified) {
+				lm = lm.Add(time.Second)
+			}
+			if !lm.After(time.Now()) {
+				w.Header().Set("Last-Modified", lm.UTC().Format(http.TimeFormat))
+				if since, err := http.ParseTime(r.Header.Get("If-Modified-Since")); err == nil && !lm.After(since) {
+					w.WriteHeader(http.StatusNotModified)
+					return
+				}
+			}
+		}
+		f.Limit = s.listPages.limit(f.Limit)
+		limit := f.Limit
+		if limit > 0 {
+			// Read one more article than asked to know whether there is a next page.
+			f.Limit++
+		}
+		articles, err := s.Query(r.Context(), f)
+		if err != nil {
+			s.writeStoreError(w, err, "could not read data")
+			return
+		}
+		if limit > 0 {
+			more := len(articles) > limit
+			if more {
+				articles = articles[:limit]
+			}
+			w.Header().Set("Link", pageLinks(r, s.origin(r), limit, f.Offset, more))
+		}
+
+		s.writeJSON(w, r, http.StatusOK, s.view(r, articles))
+	})})
+
+	m.Handle("/list/ids", methodDispatcher{http.MethodGet: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		f, err := s.queryFilter(r)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		ids, err := s.ListIDs(r.Context(), f)
+		if err != nil {
+			s.writeStoreError(w, err, "could not read data")
+			return
+		}
+
+		s.writeJSON(w, r, http.StatusOK, ids)
+	})})
+
+	m.Handle("/search", methodDispatcher{http.MethodGet: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		q := r.URL.Query().Get("q")
+		limit, offset, err := pageParams(r)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		limit = s.searchPages.limit(limit)
+		opts, err := s.searchOptions(r)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if limit > 0 {
+			opts = append(opts, SearchPage(limit, offset))
+		}
+		articles, err := s.Search(ctx, q, opts...)
+		if err != nil {
+			s.writeStoreError(w, err, "could not read data")
+			return
+		}
+		n, err := s.SearchCount(ctx, q, opts...)
+		if err != nil {
+			s.writeStoreError(w, err, "could not count data")
+			return
+		}
+
+		w.Header().Set("X-Total-Count", strconv.Itoa(n))
+		if limit > 0 {
+			w.Header().Set("Link", pageLinks(r, s.origin(r), limit, offset, offset+len(articles) < n))
+		}
+		s.writeJSON(w, r, http.StatusOK, s.view(r, articles))
+	})})
+
+	m.Handle("/search/stream", methodDispatcher{http.MethodGet: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		opts, err := s.searchOptions(r)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		nw := newNDJSONWriter(w)
+		err = s.SearchEach(ctx, r.URL.Query().Get("q"), func(a Article) error {
+			return nw.Write(s.view(r, a))
+		}, opts...)
+		if err != nil && nw.n == 0 && ctx.Err() == nil {
+			s.writeStoreError(w, err, "could not read data")
+			return
+		}
+		if err != nil {
+			s.logger().Warn("search stream stopped", slog.Any("error", err))
+		}
+		nw.Flush()
+	})})
+
+	m.Handle("/schema/article", methodDispatcher{http.MethodGet: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		s.writeJSON(w, r, http.StatusOK, s.ArticleSchema())
+	})})
+
+	m.Handle("/openapi.json", methodDispatcher{http.MethodGet: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, openAPISpec)
+	})})
+
+	m.Handle("/random", methodDispatcher{http.MethodGet: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		a, err := s.Random(ctx)
+		if errors.Is(err, ErrNotFound) {
+			writeError(w, http.StatusNotFound, "not found")
+			return
+		}
+		if err != nil {
+			s.writeStoreError(w, err, "could not read data")
+			return
+		}
+
+		s.writeJSON(w, r, http.StatusOK, s.view(r, a))
+	})})
+
+	m.Handle("/articles/order", methodDispatcher{http.MethodPut: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !isJSON(r) {
+			writeError(w, http.StatusBadRequest, "bad request")
+			return
+		}
+		var ids []string
+		err := json.NewDecoder(r.Body).Decode(&ids)
+		r.Body.Close()
+		if err != nil {
+			writeError(w, http.StatusBadRequest, decodeErrorMessage(err))
+			return
+		}
+		err = s.Reorder(r.Context(), ids)
+		if errors.Is(err, ErrNotFound) {
+			writeError(w, http.StatusNotFound, err.Error())
+			return
+		}
+		if err != nil {
+			s.writeStoreError(w, err, "could not reorder articles")
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	})})
+
+	collectionRoutes := make(map[string]http.Handler)
+	m.Handle("/articles", methodDispatcher(collectionRoutes))
+	collectionRoutes[http.MethodGet] = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var ids []string
+		for _, v := range r.URL.Query()["ids"] {
+			for _, id := range strings.Split(v, ",") {
+				if id = strings.TrimSpace(id); id != "" {
+					ids = append(ids, id)
+				}
+			}
+		}
+		if len(ids) == 0 || len(ids) > maxGetMany {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("ids must list between 1 and %d article ids", maxGetMany))
+			return
+		}
+		strict, _ := strconv.ParseBool(r.URL.Query().Get("strict"))
+		var articles interface{}
+		var err error
+		if strict {
+			articles, err = s.GetManyAligned(r.Context(), ids)
+		} else {
+			articles, err = s.GetMany(r.Context(), ids)
+		}
+		if err != nil {
+			s.writeStoreError(w, err, "could not read data")
+			return
+		}
+
+		s.writeJSON(w, r, http.StatusOK, s.view(r, articles))
+	})
+	collectionRoutes[http.MethodPut] = s.requireRole(RoleAdmin, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !isJSON(r) {
+			writeError(w, http.StatusBadRequest, "bad request")
+			return
+		}
+		var items []Article
+		err := json.NewDecoder(r.Body).Decode(&items)
+		r.Body.Close()
+		if err != nil {
+			writeError(w, http.StatusBadRequest, decodeErrorMessage(err))
+			return
+		}
+		logArticle(r, "replace_all", "")
+		err = s.ReplaceAll(r.Context(), items)
+		var invalid *ValidationError
+		if errors.As(err, &invalid) {
+			writeValidationError(w, invalid)
+			return
+		}
+		if errors.Is(err, ErrAlreadyExists) {
+			writeError(w, http.StatusConflict, err.Error())
+			return
+		}
+		if err != nil {
+			s.writeStoreError(w, err, "could not replace articles")
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
 
-// Create creates a article
-func (s ArticleService) Create(ctx context.Context, i Article) error {
-	stat := `INSERT INTO articles (title, description, content) VALUES(?,?,?);`
-	if s.DB == nil {
-		panic("no existing database")
-	}
-	_, err := s.DB.ExecContext(ctx, stat, i.Title, i.Desc, i.Content)
-	return err
-}
+	m.Handle("/authors", methodDispatcher{http.MethodGet: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authors, err := s.Authors(r.Context())
+		if err != nil {
+			s.writeStoreError(w, err, "could not read data")
+			return
+		}
 
-// Get reads an article
-func (s ArticleService) Get(ctx context.Context, id string) (*Article, error) {
-	stat := `SELECT id, title, description, content FROM articles WHERE id = ?;`
-	if s.DB == nil {
-		panic("no existing database")
-	}
-	rows, err := s.DB.QueryContext(ctx, stat, id)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
+		s.writeJSON(w, r, http.StatusOK, authors)
+	})})
 
-	var article Article
-	if rows.Next() {
-		err := rows.Scan(&article.ID, &article.Title, &article.Desc, &article.Content)
+	m.Handle("/authors/{author}/articles", methodDispatcher{http.MethodGet: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		author := mux.Vars(r)["author"]
+		limit, offset, err := pageParams(r)
 		if err != nil {
-			return nil, err
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		limit = s.listPages.limit(limit)
+		articles, err := s.ListByAuthor(ctx, author, limit, offset)
+		if err != nil {
+			s.writeStoreError(w, err, "could not read data")
+			return
+		}
+		n, err := s.QueryCount(ctx, QueryFilter{Author: author})
+		if err != nil {
+			s.writeStoreError(w, err, "could not count data")
+			return
 		}
-	}
-	return &article, err
-}
 
-// List reads all articles
-func (s ArticleService) List(ctx context.Context) ([]Article, error) {
-	stat := `SELECT id, title, description, content FROM articles;`
-	if s.DB == nil {
-		panic("no existing database")
-	}
-	rows, err := s.DB.QueryContext(ctx, stat)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
+		w.Header().Set("X-Total-Count", strconv.Itoa(n))
+		if limit > 0 {
+			w.Header().Set("Link", pageLinks(r, s.origin(r), limit, offset, offset+len(articles) < n))
+		}
+		s.writeJSON(w, r, http.StatusOK, s.view(r, articles))
+	})})
 
-	ret := make([]Article, 0, 20)
-	for rows.Next() {
-		fmt.Println("got 1 record")
-		var article Article
-		err := rows.Scan(&article.ID, &article.Title, &article.Desc, &article.Content)
+	m.Handle("/count", methodDispatcher{http.MethodGet: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var count struct {
+			Count int  `json:"count"`
+			Exact bool `json:"exact"`
+		}
+		var err error
+		if approx, _ := strconv.ParseBool(r.URL.Query().Get("approx")); approx {
+			count.Count, count.Exact, err = s.EstimateCount(r.Context())
+		} else {
+			count.Count, err = s.Count(r.Context())
+			count.Exact = true
+		}
 		if err != nil {
-			log.Println(err)
-			continue
+			s.writeStoreError(w, err, "could not count data")
+			return
 		}
-		ret = append(ret, article)
-	}
-	return ret, err
 
-}
+		s.writeJSON(w, r, http.StatusOK, count)
+	})})
 
-// Delete deletes an article
-func (s ArticleService) Delete(ctx context.Context, id string) error {
-	stat := `DELETE FROM article WHERE id = ?;`
-	_, err := s.DB.ExecContext(ctx, stat, id)
-	return err
-}
+	m.Handle("/trending", methodDispatcher{http.MethodGet: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		window := defaultTrendingWindow
+		if v := r.URL.Query().Get("window"); v != "" {
+			d, err := time.ParseDuration(v)
+			if err != nil || d <= 0 {
+				writeError(w, http.StatusBadRequest, fmt.Sprintf("window must be a positive duration such as 24h, got %q", v))
+				return
+			}
+			window = d
+		}
+		limit, _, err := pageParams(r)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		articles, err := s.Trending(r.Context(), window, trendingPages.limit(limit))
+		if err != nil {
+			s.writeStoreError(w, err, "could not read data")
+			return
+		}
 
-var defaultHandler http.Handler
+		s.writeJSON(w, r, http.StatusOK, s.view(r, articles))
+	})})
 
-// RESTful returns RESTful API of article service.
-// It contains its routes and handle http requests.
-func (s ArticleService) RESTful() http.Handler {
-	o := sync.Once{}
-	o.Do(s.registerRoutes)
+	m.Handle("/stats/status", methodDispatcher{http.MethodGet: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		counts, err := s.CountByStatus(r.Context())
+		if err != nil {
+			s.writeStoreError(w, err, "could not count data")
+			return
+		}
 
-	return defaultHandler
-}
+		s.writeJSON(w, r, http.StatusOK, counts)
+	})})
 
-func (s ArticleService) registerRoutes() {
-	m := mux.NewRouter().StrictSlash(false)
-	defaultHandler = m
+	m.Handle("/sync", methodDispatcher{http.MethodGet: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var since time.Time
+		if v := r.URL.Query().Get("since"); v != "" {
+			var err error
+			if since, err = time.Parse(time.RFC3339Nano, v); err != nil {
+				writeError(w, http.StatusBadRequest, fmt.Sprintf("since must be an RFC 3339 date, got %q", v))
+				return
+			}
+		}
+		// Taken before reading, so that changes made meanwhile are read again next time rather than missed.
+		now := time.Now().UTC()
+		var changed []Article
+		var deleted []Tombstone
+		err := s.inTx(r.Context(), &sql.TxOptions{ReadOnly: true}, func(ts *ArticleService) error {
+			var err error
+			if changed, err = ts.ModifiedSince(r.Context(), since); err != nil {
+				return err
+			}
+			deleted, err = ts.DeletedSince(r.Context(), since)
+			return err
+		})
+		if err != nil {
+			s.writeStoreError(w, err, "could not read data")
+			return
+		}
+		w.Header().Set("X-Sync-Time", now.Format(time.RFC3339Nano))
 
-	m.HandleFunc("/list", func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != http.MethodGet {
-			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		s.writeJSON(w, r, http.StatusOK, struct {
+			Changed interface{} `json:"changed"`
+			Deleted []Tombstone `json:"deleted"`
+		}{s.view(r, changed), deleted})
+	})})
+
+	m.Handle("/export/delta", methodDispatcher{http.MethodGet: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var from, to time.Time
+		bounds := []struct {
+			name string
+			t    *time.Time
+		}{{"from", &from}, {"to", &to}}
+		for _, b := range bounds {
+			v := r.URL.Query().Get(b.name)
+			var err error
+			if *b.t, err = time.Parse(time.RFC3339Nano, v); err != nil {
+				writeError(w, http.StatusBadRequest, fmt.Sprintf("%s must be an RFC 3339 date, got %q", b.name, v))
+				return
+			}
+		}
+		if from.After(to) {
+			writeError(w, http.StatusBadRequest, "from must not be after to")
 			return
 		}
-		ctx := r.Context()
-		articles, err := s.List(ctx)
+		var changed []Article
+		var deleted []Tombstone
+		err := s.inTx(r.Context(), &sql.TxOptions{ReadOnly: true}, func(ts *ArticleService) error {
+			var err error
+			if changed, err = ts.ChangedBetween(r.Context(), from, to); err != nil {
+				return err
+			}
+			deleted, err = ts.DeletedBetween(r.Context(), from, to)
+			return err
+		})
 		if err != nil {
-			http.Error(w, "could not read data", http.StatusInternalServerError)
+			s.writeStoreError(w, err, "could not read data")
 			return
 		}
 
-		b := &bytes.Buffer{}
-		if err := json.NewEncoder(b).Encode(articles); err != nil {
-			http.Error(w, "could not encode json", http.StatusInternalServerError)
+		s.writeJSON(w, r, http.StatusOK, struct {
+			Changed interface{} `json:"changed"`
+			Deleted []Tombstone `json:"deleted"`
+		}{s.view(r, changed), deleted})
+	})})
+
+	m.Handle("/debug/info", methodDispatcher{http.MethodGet: s.requireRole(RoleAdmin, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		applied, err := s.AppliedSchemaVersion(r.Context())
+		if err != nil {
+			s.writeStoreError(w, err, "could not read schema version")
 			return
 		}
-		b.WriteTo(w)
+		// Schemas before version 10 don't record their id strategy.
+		var strategy string
+		if applied == schemaVersion {
+			if strategy, err = s.appliedIDStrategy(r.Context()); err != nil {
+				s.writeStoreError(w, err, "could not read schema version")
+				return
+			}
+		}
+		info := struct {
+			Version              string `json:"version"`
+			SchemaVersion        int    `json:"schema_version"`
+			AppliedSchemaVersion int    `json:"applied_schema_version"`
+			IDStrategy           string `json:"id_strategy"`
+			AppliedIDStrategy    string `json:"applied_id_strategy"`
+			SchemaDrift          bool   `json:"schema_drift"`
+			Driver               string `json:"driver"`
+		}{Version, schemaVersion, applied, s.idStrategy(), strategy, applied != schemaVersion || strategy != s.idStrategy(), fmt.Sprintf("%T", s.DB.Driver())}
+		s.writeJSON(w, r, http.StatusOK, info)
+	}))})
 
-	})
+	m.Handle("/debug/dbstats", methodDispatcher{http.MethodGet: s.requireRole(RoleAdmin, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		st := s.Stats()
+		stats := struct {
+			MaxOpenConnections int   `json:"max_open_connections"`
+			OpenConnections    int   `json:"open_connections"`
+			InUse              int   `json:"in_use"`
+			Idle               int   `json:"idle"`
+			WaitCount          int64 `json:"wait_count"`
+			WaitDurationMillis int64 `json:"wait_duration_ms"`
+			MaxIdleClosed      int64 `json:"max_idle_closed"`
+			MaxIdleTimeClosed  int64 `json:"max_idle_time_closed"`
+			MaxLifetimeClosed  int64 `json:"max_lifetime_closed"`
+		}{st.MaxOpenConnections, st.OpenConnections, st.InUse, st.Idle, st.WaitCount, st.WaitDuration.Milliseconds(),
+			st.MaxIdleClosed, st.MaxIdleTimeClosed, st.MaxLifetimeClosed}
+		s.writeJSON(w, r, http.StatusOK, stats)
+	}))})
+
+	m.Handle("/debug/routes", methodDispatcher{http.MethodGet: s.requireRole(RoleAdmin, http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		s.writeJSON(w, req, http.StatusOK, listRoutes(r))
+	}))})
 
 	articleRoutes := make(map[string]http.Handler)
 
 	m.Handle("/article/{id}", methodDispatcher(articleRoutes))
-	m.HandleFunc("/article", func(w http.ResponseWriter, r *http.Request) {
-		ct := r.Header.Get("Content-Type")
-		if ct != "application/json" {
-			http.Error(w, "bad request", http.StatusBadRequest)
+	m.Handle("/article/{id}/neighbors", methodDispatcher{http.MethodGet: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		prev, next, err := s.Neighbors(r.Context(), mux.Vars(r)["id"])
+		if errors.Is(err, ErrNotFound) {
+			writeError(w, http.StatusNotFound, "not found")
 			return
 		}
-		var article Article
-		err := json.NewDecoder(r.Body).Decode(&article)
+		if err != nil {
+			s.writeStoreError(w, err, "could not read data")
+			return
+		}
+		neighbors := map[string]interface{}{"prev": nil, "next": nil}
+		if prev != nil {
+			neighbors["prev"] = s.view(r, prev)
+		}
+		if next != nil {
+			neighbors["next"] = s.view(r, next)
+		}
+
+		s.writeJSON(w, r, http.StatusOK, neighbors)
+	})})
+	m.Handle("/article/{id}/stats", methodDispatcher{http.MethodGet: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Counting words tells about the content.
+		if s.hides(r, "content") {
+			writeError(w, http.StatusForbidden, "forbidden")
+			return
+		}
+		a, err := s.Get(r.Context(), mux.Vars(r)["id"])
+		if errors.Is(err, ErrNotFound) {
+			writeError(w, http.StatusNotFound, "not found")
+			return
+		}
+		if err != nil {
+			s.writeStoreError(w, err, "could not read data")
+			return
+		}
+
+		s.writeJSON(w, r, http.StatusOK, a.Stats())
+	})})
+	content := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.hides(r, "content") {
+			writeError(w, http.StatusForbidden, "forbidden")
+			return
+		}
+		a, err := s.Get(r.Context(), mux.Vars(r)["id"])
+		if errors.Is(err, ErrNotFound) {
+			writeError(w, http.StatusNotFound, "not found")
+			return
+		}
+		if err != nil {
+			s.writeStoreError(w, err, "could not read data")
+			return
+		}
+		// ServeContent answers Range requests with 206 and Content-Range, and conditional ones from updated_at.
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		http.ServeContent(w, r, "", a.UpdatedAt, strings.NewReader(a.Content))
+	})
+	m.Handle("/article/{id}/content", methodDispatcher{http.MethodGet: content, http.MethodHead: content})
+	m.Handle("/article/{id}/archive", methodDispatcher{http.MethodPost: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := mux.Vars(r)["id"]
+		logArticle(r, "archive", id)
+		err := s.Archive(r.Context(), id)
+		if errors.Is(err, ErrNotFound) {
+			writeError(w, http.StatusNotFound, "not found")
+			return
+		}
+		if errors.Is(err, ErrAlreadyExists) {
+			writeError(w, http.StatusConflict, err.Error())
+			return
+		}
+		if err != nil {
+			s.writeStoreError(w, err, "could not archive article")
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	})})
+	m.Handle("/article/{id}/unarchive", methodDispatcher{http.MethodPost: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := mux.Vars(r)["id"]
+		logArticle(r, "unarchive", id)
+		err := s.Unarchive(r.Context(), id)
+		if errors.Is(err, ErrNotFound) {
+			writeError(w, http.StatusNotFound, "not found")
+			return
+		}
+		if errors.Is(err, ErrAlreadyExists) {
+			writeError(w, http.StatusConflict, err.Error())
+			return
+		}
+		if err != nil {
+			s.writeStoreError(w, err, "could not restore article")
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	})})
+	m.Handle("/article/{id}/touch", methodDispatcher{http.MethodPost: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		logArticle(r, "touch", mux.Vars(r)["id"])
+		err := s.Touch(ctx, mux.Vars(r)["id"])
+		if errors.Is(err, ErrNotFound) {
+			writeError(w, http.StatusNotFound, "not found")
+			return
+		}
+		if err != nil {
+			s.writeStoreError(w, err, "could not touch article")
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	})})
+	m.Handle("/article/{id}/view", methodDispatcher{http.MethodPost: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := mux.Vars(r)["id"]
+		logArticle(r, "view", id)
+		views, err := s.IncrementViews(r.Context(), id)
+		if errors.Is(err, ErrNotFound) {
+			writeError(w, http.StatusNotFound, "not found")
+			return
+		}
+		if err != nil {
+			s.writeStoreError(w, err, "could not count view")
+			return
+		}
+
+		s.writeJSON(w, r, http.StatusOK, struct {
+			Views int `json:"views"`
+		}{views})
+	})})
+	m.Handle("/article/{id}/import", methodDispatcher{http.MethodPost: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !isJSON(r) {
+			writeError(w, http.StatusBadRequest, "bad request")
+			return
+		}
+		var body struct {
+			URL string `json:"url"`
+		}
+		err := json.NewDecoder(r.Body).Decode(&body)
 		r.Body.Close()
 		if err != nil {
-			http.Error(w, fmt.Sprintf("could not decode json: %v", err), http.StatusBadRequest)
+			writeError(w, http.StatusBadRequest, decodeErrorMessage(err))
+			return
+		}
+		id := mux.Vars(r)["id"]
+		logArticle(r, "import", id)
+		err = s.ImportContentFromURL(r.Context(), id, body.URL)
+		var invalid *ValidationError
+		switch {
+		case err == nil:
+			w.WriteHeader(http.StatusOK)
+		case errors.Is(err, ErrNotFound):
+			writeError(w, http.StatusNotFound, "not found")
+		case errors.Is(err, ErrImportBlocked):
+			writeError(w, http.StatusBadRequest, "url not allowed")
+		case errors.As(err, &invalid):
+			writeValidationError(w, invalid)
+		case errors.Is(err, ErrImportFailed):
+			writeError(w, http.StatusBadGateway, "could not fetch content")
+		default:
+			s.writeStoreError(w, err, "could not import content")
+		}
+	})})
+	m.Handle("/article", methodDispatcher{http.MethodPost: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		logArticle(r, "create", "")
+		var article Article
+		if !s.decodeArticle(w, r, &article) {
 			return
 		}
 		ctx := r.Context()
-		if err := s.Create(ctx, article); err != nil {
-			http.Error(w, fmt.Sprintf("fail to create: %v", err), http.StatusInternalServerError)
+		created, err := s.Create(ctx, article)
+		var invalid *ValidationError
+		if errors.As(err, &invalid) {
+			writeValidationError(w, invalid)
 			return
 		}
-		w.WriteHeader(http.StatusCreated)
-	})
+		if err != nil {
+			s.writeStoreError(w, err, "fail to create")
+			return
+		}
+		logArticle(r, "create", created.ID)
+
+		w.Header().Set("Location", s.origin(r)+articleLocation(r, created.ID))
+		s.writeJSON(w, r, http.StatusCreated, s.view(r, created))
+	})})
 
 	articleRoutes[http.MethodGet] = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		id := mux.Vars(r)["id"]
+		logArticle(r, "get", id)
+		// An article may change at any time, so don't let it be served stale.
+		w.Header().Set("Cache-Control", "no-store")
 		if id == "" {
-			http.Error(w, "bad request", http.StatusBadRequest)
+			writeError(w, http.StatusBadRequest, "bad request")
 			return
 		}
 		ctx := r.Context()
 		a, err := s.Get(ctx, id)
+		if errors.Is(err, ErrNotFound) {
+			writeError(w, http.StatusNotFound, "not found")
+			return
+		}
+		if err != nil {
+			s.writeStoreError(w, err, "could not read data")
+			return
+		}
+
+		s.writeJSON(w, r, http.StatusOK, s.view(r, a))
+	})
+
+	articleRoutes[http.MethodPut] = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := mux.Vars(r)["id"]
+		logArticle(r, "create", id)
+		if r.Header.Get("If-None-Match") != "*" {
+			writeError(w, http.StatusPreconditionRequired, "PUT only creates articles, send If-None-Match: *")
+			return
+		}
+		if !isJSON(r) {
+			writeError(w, http.StatusBadRequest, "bad request")
+			return
+		}
+		var article Article
+		err := json.NewDecoder(r.Body).Decode(&article)
+		r.Body.Close()
+		if err != nil {
+			writeError(w, http.StatusBadRequest, decodeErrorMessage(err))
+			return
+		}
+		article.ID = id
+		ctx := r.Context()
+		err = s.CreateWithID(ctx, article)
+		var invalid *ValidationError
+		switch {
+		case errors.As(err, &invalid):
+			writeValidationError(w, invalid)
+			return
+		case errors.Is(err, ErrAlreadyExists):
+			writeError(w, http.StatusConflict, err.Error())
+			return
+		case err != nil:
+			s.writeStoreError(w, err, "fail to create")
+			return
+		}
+		created, err := s.Get(ctx, id)
 		if err != nil {
-			http.Error(w, fmt.Sprintf("could not read id: %v", err), http.StatusInternalServerError)
+			s.writeStoreError(w, err, "could not read created article")
 			return
 		}
 
-		json.NewEncoder(w).Encode(a)
+		w.Header().Set("Location", s.origin(r)+requestPath(r))
+		s.writeJSON(w, r, http.StatusCreated, s.view(r, created))
 	})
 
 	articleRoutes[http.MethodDelete] = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		id := mux.Vars(r)["id"]
+		logArticle(r, "delete", id)
 		if id == "" {
-			http.Error(w, "bad request", http.StatusBadRequest)
+			writeError(w, http.StatusBadRequest, "bad request")
 			return
 		}
 		ctx := r.Context()
-		if err := s.Delete(ctx, id); err != nil {
-			http.Error(w, "error", http.StatusInternalServerError)
+		err := s.Delete(ctx, id)
+		if errors.Is(err, ErrNotFound) {
+			writeError(w, http.StatusNotFound, "not found")
+			return
+		}
+		if err != nil {
+			s.writeStoreError(w, err, "could not delete article")
 			return
 		}
 		w.WriteHeader(http.StatusOK)
 	})
 }
 
+// searchOptions reads the SearchOption values requested by the query of r, failing on an unknown mode, and
+// excludes the fields hidden from its caller.
+func (s *ArticleService) searchOptions(r *http.Request) ([]SearchOption, error) {
+	var opts []SearchOption
+	// Matching on a field the caller must not see would tell what it holds.
+	if hidden := s.fieldPolicy[roleFrom(r.Context())]; len(hidden) > 0 {
+		opts = append(opts, ExcludeFields(hidden...))
+	}
+	if ci, _ := strconv.ParseBool(r.URL.Query().Get("ci")); ci {
+		opts = append(opts, IgnoreCase())
+	}
+	switch mode := SearchMode(r.URL.Query().Get("mode")); mode {
+	case "", MatchSubstring:
+	case MatchPhrase, MatchWords:
+		opts = append(opts, Match(mode))
+	default:
+		return nil, fmt.Errorf("mode must be one of %s, %s or %s, got %q", MatchSubstring, MatchPhrase, MatchWords, mode)
+	}
+	return opts, nil
+}
+
+// isJSON reports whether r has a json body, allowing media type parameters such as charset.
+func isJSON(r *http.Request) bool {
+	mt, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	return err == nil && mt == "application/json"
+}
+
+// decodeErrorMessage turns a json decoding error into a message for clients,
+// naming the offending field and byte offset when the decoder reports them.
+func decodeErrorMessage(err error) string {
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &syntaxErr):
+		return fmt.Sprintf("malformed json at byte offset %d", syntaxErr.Offset)
+	case errors.As(err, &typeErr):
+		if typeErr.Field == "" {
+			return fmt.Sprintf("request body has the wrong json type, got %s", typeErr.Value)
+		}
+		return fmt.Sprintf("field %q should be %s, got %s at byte offset %d", typeErr.Field, typeErr.Type, typeErr.Value, typeErr.Offset)
+	case errors.Is(err, io.EOF):
+		return "request body is empty"
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		return "malformed json: unexpected end of body"
+	}
+	return fmt.Sprintf("could not decode json: %v", err)
+}
+
+// origin returns the scheme and host r was sent to, such as "https://example.com", when URLs in
+// headers are to be absolute as set by WithAbsoluteURLs, and "" otherwise.
+// Behind a proxy, they are taken from the X-Forwarded-Proto and X-Forwarded-Host headers.
+func (s *ArticleService) origin(r *http.Request) string {
+	if !s.absoluteURLs {
+		return ""
+	}
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	if proto := forwarded(r, "X-Forwarded-Proto"); proto != "" {
+		scheme = strings.ToLower(proto)
+	}
+	host := r.Host
+	if h := forwarded(r, "X-Forwarded-Host"); h != "" {
+		host = h
+	}
+	return scheme + "://" + host
+}
+
+// forwarded returns the value of header set by the proxy closest to the client, the first one
+// when proxies append theirs.
+func forwarded(r *http.Request, header string) string {
+	return strings.TrimSpace(strings.Split(r.Header.Get(header), ",")[0])
+}
+
+// articleLocation returns the path of article id from a request to the collection of articles.
+func articleLocation(r *http.Request, id string) string {
+	return requestPath(r) + "/" + url.PathEscape(id)
+}
+
+// defaultTrendingWindow is the window of /trending requests that give none.
+const defaultTrendingWindow = 24 * time.Hour
+
+// trendingPages are the page sizes of /trending.
+var trendingPages = PageLimits{Default: 10, Max: 100}
+
+// PageLimits sets the page sizes of a route, for WithListPageLimits and WithSearchPageLimits.
+// A limit asked by the client wins over Default, but never over Max. Zero values mean no default and no maximum.
+type PageLimits struct {
+	// Default is the limit of requests that give none. If zero, all results are returned, up to Max.
+	Default int
+	// Max caps the limit, whether defaulted or asked by the client.
+	Max int
+}
+
+// limit returns the page size for a request asking for asked articles, zero meaning all of them.
+func (l PageLimits) limit(asked int) int {
+	if asked == 0 {
+		asked = l.Default
+	}
+	if l.Max > 0 && (asked == 0 || asked > l.Max) {
+		asked = l.Max
+	}
+	return asked
+}
+
+// pageParams reads the limit and offset query parameters of r, zero when absent.
+func pageParams(r *http.Request) (limit, offset int, err error) {
+	q := r.URL.Query()
+	if v := q.Get("limit"); v != "" {
+		if limit, err = strconv.Atoi(v); err != nil || limit < 1 {
+			return 0, 0, fmt.Errorf("limit must be a positive integer, got %q", v)
+		}
+	}
+	if v := q.Get("offset"); v != "" {
+		if offset, err = strconv.Atoi(v); err != nil || offset < 0 {
+			return 0, 0, fmt.Errorf("offset must be a non-negative integer, got %q", v)
+		}
+	}
+	return limit, offset, nil
+}
+
+// pageLinks builds the RFC 8288 Link header of a page of limit articles from offset, pointing to
+// the first page, and to the previous and next pages unless at either end.
+func pageLinks(r *http.Request, origin string, limit, offset int, more bool) string {
+	link := func(offset int, rel string) string {
+		q := r.URL.Query()
+		q.Set("limit", strconv.Itoa(limit))
+		q.Set("offset", strconv.Itoa(offset))
+		return fmt.Sprintf(`<%s%s?%s>; rel="%s"`, origin, requestPath(r), q.Encode(), rel)
+	}
+	links := []string{link(0, "first")}
+	if offset > 0 {
+		prev := offset - limit
+		if prev < 0 {
+			prev = 0
+		}
+		links = append(links, link(prev, "prev"))
+	}
+	if more {
+		links = append(links, link(offset+limit, "next"))
+	}
+	return strings.Join(links, ", ")
+}
+
+// requestPath returns the path r was sent to, without trailing slash. It is taken from the request URI
+// so that it holds behind a prefix stripped by http.StripPrefix.
+func requestPath(r *http.Request) string {
+	p := r.URL.Path
+	if u, err := url.ParseRequestURI(r.RequestURI); err == nil {
+		p = u.Path
+	}
+	return strings.TrimRight(p, "/")
+}
+
+// trimTrailingSlash is our trailing slash policy: every route answers the same with or without one,
+// so /list and /list/ both list. The path is rewritten instead of redirected, since clients often
+// replay a redirected POST or DELETE as a GET.
+func trimTrailingSlash(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if len(r.URL.Path) > 1 && strings.HasSuffix(r.URL.Path, "/") {
+			u := *r.URL
+			u.Path = strings.TrimRight(u.Path, "/")
+			if u.Path == "" {
+				u.Path = "/"
+			}
+			u.RawPath = ""
+			r2 := new(http.Request)
+			*r2 = *r
+			r2.URL = &u
+			r = r2
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
 type methodDispatcher map[string]http.Handler
 
+// routeInfo describes a route of a router, for /debug/routes.
+type routeInfo struct {
+	Path string `json:"path"`
+	// Methods are those the route answers, unset for routes of r's owner that check the method themselves.
+	Methods []string `json:"methods,omitempty"`
+}
+
+// listRoutes lists the path templates of the routes of r, subrouters included, in the order they are matched.
+func listRoutes(r *mux.Router) []routeInfo {
+	var routes []routeInfo
+	r.Walk(func(route *mux.Route, router *mux.Router, ancestors []*mux.Route) error {
+		path, err := route.GetPathTemplate()
+		if err != nil {
+			// A route without a path, such as the one holding a subrouter.
+			return nil
+		}
+		info := routeInfo{Path: path}
+		if methods, err := route.GetMethods(); err == nil {
+			info.Methods = methods
+		} else if d, ok := route.GetHandler().(methodDispatcher); ok {
+			for method := range d {
+				info.Methods = append(info.Methods, method)
+			}
+			sort.Strings(info.Methods)
+		}
+		routes = append(routes, info)
+		return nil
+	})
+	return routes
+}
+
 func (mux methodDispatcher) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	if h, ok := mux[r.Method]; ok {
 		h.ServeHTTP(w, r)
 		return
 	}
 
-	http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	writeError(w, http.StatusMethodNotAllowed, "method not allowed")
 }
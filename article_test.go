@@ -0,0 +1,2084 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	_ "github.com/mattn/go-sqlite3"
+	_ "github.com/proullon/ramsql/driver"
+)
+
+// newTestService returns a service on a fresh in-memory SQLite database, prepared by Prepare.
+// It logs nothing unless opts set a logger.
+func newTestService(t *testing.T, opts ...Option) *ArticleService {
+	t.Helper()
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Every connection to :memory: opens a database of its own, so keep to one.
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+	s := New(db, append([]Option{WithLogger(slog.New(slog.NewTextHandler(io.Discard, nil)))}, opts...)...)
+	s.Prepare(context.Background())
+	return s
+}
+
+// mustCreate creates a and returns it as stored.
+func mustCreate(t *testing.T, s *ArticleService, a Article) *Article {
+	t.Helper()
+	created, err := s.Create(context.Background(), a)
+	if err != nil {
+		t.Fatalf("could not create %+v: %v", a, err)
+	}
+	return created
+}
+
+// serve sends a request to h and returns the recorded response. A body is sent as json, and headers
+// are pairs of names and values, which may override the Content-Type.
+func serve(h http.Handler, method, target, body string, headers ...string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for i := 0; i+1 < len(headers); i += 2 {
+		req.Header.Set(headers[i], headers[i+1])
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+// decode decodes the json body of rec into v.
+func decode(t *testing.T, rec *httptest.ResponseRecorder, v interface{}) {
+	t.Helper()
+	if err := json.Unmarshal(rec.Body.Bytes(), v); err != nil {
+		t.Fatalf("could not decode %q: %v", rec.Body.String(), err)
+	}
+}
+
+// errorOf decodes the error envelope of rec.
+func errorOf(t *testing.T, rec *httptest.ResponseRecorder) apiError {
+	t.Helper()
+	var envelope struct {
+		Error apiError `json:"error"`
+	}
+	decode(t, rec, &envelope)
+	return envelope.Error
+}
+
+// idsOf returns the ids of articles, in order.
+func idsOf(articles []Article) []string {
+	ids := make([]string, len(articles))
+	for i, a := range articles {
+		ids[i] = a.ID
+	}
+	return ids
+}
+
+// holdConn takes the only connection of the pool of s until the test ends or release is called,
+// so that statements wait for one.
+func holdConn(t *testing.T, s *ArticleService) (release func()) {
+	t.Helper()
+	c, err := s.DB.Conn(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var once sync.Once
+	release = func() { once.Do(func() { c.Close() }) }
+	t.Cleanup(release)
+	return release
+}
+
+// recordingDriver is a database/sql driver that stores nothing and records the statements it is given,
+// answering each with no rows.
+type recordingDriver struct {
+	mu         sync.Mutex
+	statements []recordedStatement
+}
+
+// recordedStatement is a statement given to a recordingDriver, with the deadline of its context, if any.
+type recordedStatement struct {
+	query    string
+	deadline time.Time
+}
+
+// openRecording returns a database on a fresh recordingDriver, and the driver.
+func openRecording(t *testing.T) (*sql.DB, *recordingDriver) {
+	d := &recordingDriver{}
+	db := sql.OpenDB(d)
+	t.Cleanup(func() { db.Close() })
+	return db, d
+}
+
+// recorded returns the statements given so far.
+func (d *recordingDriver) recorded() []recordedStatement {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]recordedStatement(nil), d.statements...)
+}
+
+func (d *recordingDriver) record(ctx context.Context, query string) {
+	deadline, _ := ctx.Deadline()
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.statements = append(d.statements, recordedStatement{query, deadline})
+}
+
+func (d *recordingDriver) Connect(context.Context) (driver.Conn, error) { return recordingConn{d}, nil }
+func (d *recordingDriver) Driver() driver.Driver                        { return nil }
+
+type recordingConn struct{ d *recordingDriver }
+
+func (c recordingConn) Prepare(string) (driver.Stmt, error) { return nil, errors.New("not supported") }
+func (c recordingConn) Close() error                        { return nil }
+func (c recordingConn) Begin() (driver.Tx, error)           { return c, nil }
+func (c recordingConn) Commit() error                       { return nil }
+func (c recordingConn) Rollback() error                     { return nil }
+
+func (c recordingConn) ExecContext(ctx context.Context, query string, _ []driver.NamedValue) (driver.Result, error) {
+	c.d.record(ctx, query)
+	return driver.RowsAffected(1), nil
+}
+
+func (c recordingConn) QueryContext(ctx context.Context, query string, _ []driver.NamedValue) (driver.Rows, error) {
+	c.d.record(ctx, query)
+	return noRows{}, nil
+}
+
+type noRows struct{}
+
+func (noRows) Columns() []string         { return nil }
+func (noRows) Close() error              { return nil }
+func (noRows) Next([]driver.Value) error { return io.EOF }
+
+func TestCreateAndGet(t *testing.T) {
+	s := newTestService(t)
+	ctx := context.Background()
+	created := mustCreate(t, s, Article{Title: "hello", Desc: "greeting", Content: "hello world", Author: "ann", Status: "draft"})
+	if created.ID == "" || created.UpdatedAt.IsZero() {
+		t.Fatalf("created article lacks its id or timestamp: %+v", created)
+	}
+	got, err := s.Get(ctx, created.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.ID != created.ID || got.Title != "hello" || got.Content != "hello world" || got.Author != "ann" || !got.UpdatedAt.Equal(created.UpdatedAt) {
+		t.Errorf("got %+v, want %+v", got, created)
+	}
+}
+
+func TestGetMissing(t *testing.T) {
+	s := newTestService(t)
+	_, err := s.Get(context.Background(), "42")
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("got %v, want ErrNotFound", err)
+	}
+}
+
+func TestCreateWithDefaults(t *testing.T) {
+	s := newTestService(t, WithDefaults(Article{Author: "editorial", Status: "draft"}))
+	omitted := mustCreate(t, s, Article{Title: "a"})
+	if omitted.Author != "editorial" || omitted.Status != "draft" {
+		t.Errorf("defaults not applied: %+v", omitted)
+	}
+	given := mustCreate(t, s, Article{Title: "b", Author: "ann"})
+	if given.Author != "ann" || given.Status != "draft" {
+		t.Errorf("given author overridden: %+v", given)
+	}
+}
+
+func TestCreateNormalizesTitle(t *testing.T) {
+	s := newTestService(t)
+	a := mustCreate(t, s, Article{Title: "  a \t  spaced\n title  "})
+	got, err := s.Get(context.Background(), a.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Title != "a spaced title" {
+		t.Errorf("got title %q, want %q", got.Title, "a spaced title")
+	}
+}
+
+func TestCreateValidates(t *testing.T) {
+	s := newTestService(t, WithFieldLimits(FieldLimits{Title: 3}))
+	_, err := s.Create(context.Background(), Article{Title: "four"})
+	var invalid *ValidationError
+	if !errors.As(err, &invalid) || invalid.Fields["title"] == "" {
+		t.Fatalf("got %v, want a ValidationError on title", err)
+	}
+	if n, _ := s.Count(context.Background()); n != 0 {
+		t.Errorf("invalid article stored, %d articles", n)
+	}
+}
+
+func TestIDGenerators(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		opts []Option
+		want func(id string) bool
+	}{
+		{"serial", nil, func(id string) bool { _, err := strconv.Atoi(id); return err == nil }},
+		{"uuid", []Option{WithIDGenerator(RandomUUID)}, func(id string) bool { return len(id) == 36 && strings.Count(id, "-") == 4 }},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			s := newTestService(t, tc.opts...)
+			a := mustCreate(t, s, Article{Title: "a"})
+			b := mustCreate(t, s, Article{Title: "b"})
+			if !tc.want(a.ID) || a.ID == b.ID {
+				t.Fatalf("unexpected ids %q and %q", a.ID, b.ID)
+			}
+			got, err := s.Get(context.Background(), a.ID)
+			if err != nil || got.Title != "a" {
+				t.Errorf("got %+v, %v", got, err)
+			}
+		})
+	}
+}
+
+func TestCreateWithID(t *testing.T) {
+	s := newTestService(t)
+	ctx := context.Background()
+	if err := s.CreateWithID(ctx, Article{ID: "7", Title: "seven"}); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := s.Get(ctx, "7"); err != nil || got.Title != "seven" {
+		t.Fatalf("got %+v, %v", got, err)
+	}
+	err := s.CreateWithID(ctx, Article{ID: "7", Title: "again"})
+	if !errors.Is(err, ErrAlreadyExists) {
+		t.Errorf("got %v, want ErrAlreadyExists", err)
+	}
+	if got, _ := s.Get(ctx, "7"); got.Title != "seven" {
+		t.Errorf("existing article overwritten: %+v", got)
+	}
+	var invalid *ValidationError
+	if err := s.CreateWithID(ctx, Article{Title: "no id"}); !errors.As(err, &invalid) {
+		t.Errorf("got %v, want a ValidationError for the missing id", err)
+	}
+	if err := s.CreateWithID(ctx, Article{ID: "abc", Title: "a"}); !errors.As(err, &invalid) || invalid.Fields["id"] == "" {
+		t.Errorf("got %v, want a ValidationError for a serial id that is no integer", err)
+	}
+
+	// The id of an archived article stays taken, so that it can be restored.
+	if err := s.Archive(ctx, "7"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.CreateWithID(ctx, Article{ID: "7", Title: "again"}); !errors.Is(err, ErrAlreadyExists) {
+		t.Errorf("id of an archived article got %v, want ErrAlreadyExists", err)
+	}
+	if err := s.Unarchive(ctx, "7"); err != nil {
+		t.Errorf("unarchive got %v", err)
+	}
+}
+
+func TestGetMany(t *testing.T) {
+	s := newTestService(t)
+	ctx := context.Background()
+	a := mustCreate(t, s, Article{Title: "a"})
+	b := mustCreate(t, s, Article{Title: "b"})
+	ids := []string{b.ID, "404", a.ID}
+
+	many, err := s.GetMany(ctx, ids)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := idsOf(many), []string{b.ID, a.ID}; !reflect.DeepEqual(got, want) {
+		t.Errorf("GetMany got %v, want %v", got, want)
+	}
+	aligned, err := s.GetManyAligned(ctx, ids)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(aligned) != len(ids) || aligned[0].ID != b.ID || aligned[1] != nil || aligned[2].ID != a.ID {
+		t.Errorf("GetManyAligned got %v", aligned)
+	}
+}
+
+func TestListSortAndPage(t *testing.T) {
+	s := newTestService(t)
+	ctx := context.Background()
+	for _, p := range []int{2, 1, 2} {
+		mustCreate(t, s, Article{Title: "a", Position: p})
+	}
+	// Ties are broken by id, whatever the column sorted by.
+	if _, err := s.DB.Exec(`UPDATE articles SET updated_at = ?;`, time.Now().UTC()); err != nil {
+		t.Fatal(err)
+	}
+	all, err := s.List(ctx)
+	if err != nil || len(all) != 3 {
+		t.Fatalf("got %d articles, %v", len(all), err)
+	}
+	for _, tc := range []struct {
+		opts []ListOption
+		want []string
+	}{
+		{[]ListOption{SortBy("position")}, []string{"2", "1", "3"}},
+		{[]ListOption{SortBy("-position")}, []string{"1", "3", "2"}},
+		{[]ListOption{SortBy("-id")}, []string{"3", "2", "1"}},
+		{[]ListOption{Page(2, 1)}, []string{"2", "3"}},
+		{[]ListOption{SortBy("-position"), Page(1, 1)}, []string{"3"}},
+		{[]ListOption{SortBy("updated_at"), Page(2, 1)}, []string{"2", "3"}},
+		{[]ListOption{SortBy("-updated_at"), Page(2, 0)}, []string{"1", "2"}},
+	} {
+		got, err := s.List(ctx, tc.opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(idsOf(got), tc.want) {
+			t.Errorf("got %v, want %v", idsOf(got), tc.want)
+		}
+	}
+	if _, err := s.List(ctx, SortBy("title")); !errors.Is(err, ErrInvalidSort) {
+		t.Errorf("got %v, want ErrInvalidSort", err)
+	}
+}
+
+func TestReadErrorsAreReturned(t *testing.T) {
+	s := newTestService(t)
+	ctx := context.Background()
+	mustCreate(t, s, Article{Title: "a"})
+	if _, err := s.DB.Exec(`INSERT INTO articles (` + articleColumns + `) VALUES (7, NULL, 'a', '', '', '', 0, '2024-01-01');`); err != nil {
+		t.Fatal(err)
+	}
+	if articles, err := s.List(ctx); err == nil {
+		t.Errorf("List got %v and no error", idsOf(articles))
+	}
+	var seen []string
+	err := s.SearchEach(ctx, "a", func(a Article) error {
+		seen = append(seen, a.ID)
+		return nil
+	})
+	if err == nil {
+		t.Errorf("SearchEach got %v and no error", seen)
+	}
+}
+
+func TestSearchCount(t *testing.T) {
+	s := newTestService(t)
+	ctx := context.Background()
+	mustCreate(t, s, Article{Title: "go rocks", Content: "x"})
+	mustCreate(t, s, Article{Title: "rust", Desc: "not go", Content: "y"})
+	mustCreate(t, s, Article{Title: "java", Content: "going on"})
+	mustCreate(t, s, Article{Title: "zig", Content: "z"})
+	for q, want := range map[string]int{"go": 3, "rust": 1, "zig": 1, "nothing": 0} {
+		found, err := s.Search(ctx, q)
+		if err != nil {
+			t.Fatal(err)
+		}
+		n, err := s.SearchCount(ctx, q)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(found) != want || n != want {
+			t.Errorf("q=%q: found %d, counted %d, want %d", q, len(found), n, want)
+		}
+	}
+}
+
+func TestSearchIgnoreCase(t *testing.T) {
+	s := newTestService(t)
+	ctx := context.Background()
+	// SQLite's LIKE ignores the case of ASCII letters unless told otherwise, which would hide what ci changes.
+	if _, err := s.DB.Exec(`PRAGMA case_sensitive_like = ON;`); err != nil {
+		t.Fatal(err)
+	}
+	mustCreate(t, s, Article{Title: "Go Rocks"})
+	for _, q := range []string{"go rocks", "GO ROCKS", "gO"} {
+		for _, mode := range []SearchMode{MatchSubstring, MatchPhrase, MatchWords} {
+			if n, err := s.SearchCount(ctx, q, Match(mode)); err != nil || n != 0 {
+				t.Errorf("mode %s, q=%q: counted %d, %v, want 0 without ci", mode, q, n, err)
+			}
+			if n, err := s.SearchCount(ctx, q, Match(mode), IgnoreCase()); err != nil || n != 1 {
+				t.Errorf("mode %s, q=%q: counted %d, %v, want 1 with ci", mode, q, n, err)
+			}
+		}
+	}
+}
+
+func TestSearchPage(t *testing.T) {
+	s := newTestService(t)
+	ctx := context.Background()
+	for i := 0; i < 5; i++ {
+		mustCreate(t, s, Article{Title: "match"})
+	}
+	found, err := s.Search(ctx, "match", SearchPage(2, 3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := idsOf(found); !reflect.DeepEqual(got, []string{"4", "5"}) {
+		t.Errorf("got %v, want [4 5]", got)
+	}
+	if n, _ := s.SearchCount(ctx, "match", SearchPage(2, 3)); n != 5 {
+		t.Errorf("counted %d, want every match", n)
+	}
+}
+
+func TestSearchEscapesWildcards(t *testing.T) {
+	s := newTestService(t)
+	ctx := context.Background()
+	mustCreate(t, s, Article{Title: "snake_case"})
+	mustCreate(t, s, Article{Title: "100% done"})
+	mustCreate(t, s, Article{Title: "wow!"})
+	mustCreate(t, s, Article{Title: "plain"})
+	for _, mode := range []SearchMode{MatchSubstring, MatchPhrase, MatchWords} {
+		for q, want := range map[string]int{"_": 1, "%": 1, "!": 1, "e_c": 1, "0%": 1} {
+			if mode == MatchPhrase && q != "_" {
+				// Phrases match whole words.
+				continue
+			}
+			n, err := s.SearchCount(ctx, q, Match(mode))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if mode == MatchPhrase {
+				want = 0
+			}
+			if n != want {
+				t.Errorf("mode %s, q=%q: counted %d, want %d", mode, q, n, want)
+			}
+		}
+	}
+}
+
+func TestSearchExcludeFields(t *testing.T) {
+	s := newTestService(t)
+	ctx := context.Background()
+	mustCreate(t, s, Article{Title: "in title", Desc: "in description", Content: "in content"})
+	for _, tc := range []struct {
+		q        string
+		excluded []string
+		want     int
+	}{
+		{"content", nil, 1},
+		{"content", []string{"content"}, 0},
+		{"title", []string{"content", "description"}, 1},
+		{"in", []string{"title", "description", "content"}, 0},
+	} {
+		for _, mode := range []SearchMode{MatchSubstring, MatchPhrase, MatchWords} {
+			opts := []SearchOption{Match(mode), ExcludeFields(tc.excluded...)}
+			found, err := s.Search(ctx, tc.q, opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			n, err := s.SearchCount(ctx, tc.q, opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(found) != tc.want || n != tc.want {
+				t.Errorf("mode %s, q=%q excluding %v: found %d, counted %d, want %d", mode, tc.q, tc.excluded, len(found), n, tc.want)
+			}
+		}
+		articles, err := s.Query(ctx, QueryFilter{Text: tc.q, TextExcluded: tc.excluded})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(articles) != tc.want {
+			t.Errorf("Query of %q excluding %v got %d, want %d", tc.q, tc.excluded, len(articles), tc.want)
+		}
+	}
+}
+
+func TestSearchModes(t *testing.T) {
+	s := newTestService(t)
+	ctx := context.Background()
+	phrase := mustCreate(t, s, Article{Title: "the quick fox jumps"})
+	scattered := mustCreate(t, s, Article{Title: "quick", Content: "brown fox"})
+	joined := mustCreate(t, s, Article{Title: "quickfox"})
+	var punctuated []string
+	// In the content, as titles are normalized and can't hold a newline.
+	for _, content := range []string{"I saw the quick fox.", "the quick fox, then", "(quick fox)", "the quick\n\tfox"} {
+		punctuated = append(punctuated, mustCreate(t, s, Article{Title: "a", Content: content}).ID)
+	}
+	longer := mustCreate(t, s, Article{Title: "quick foxes", Content: "aquick fox"})
+	phrases := append([]string{phrase.ID}, punctuated...)
+	for _, tc := range []struct {
+		mode SearchMode
+		q    string
+		want []string
+	}{
+		{MatchPhrase, "quick fox", phrases},
+		{MatchPhrase, "  quick   fox ", phrases},
+		{MatchPhrase, "quick fox.", punctuated[:1]},
+		{MatchWords, "fox quick", append(append([]string{phrase.ID, scattered.ID, joined.ID}, punctuated...), longer.ID)},
+		{MatchSubstring, "quick fox", append(append([]string{phrase.ID}, punctuated[:3]...), longer.ID)},
+	} {
+		found, err := s.Search(ctx, tc.q, Match(tc.mode))
+		if err != nil {
+			t.Fatal(err)
+		}
+		n, err := s.SearchCount(ctx, tc.q, Match(tc.mode))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(idsOf(found), tc.want) || n != len(tc.want) {
+			t.Errorf("mode %s, q=%q: found %v, counted %d, want %v", tc.mode, tc.q, idsOf(found), n, tc.want)
+		}
+	}
+
+	// Phrases are matched once read, and paged after that.
+	opts := []SearchOption{Match(MatchPhrase), SearchPage(2, 1)}
+	found, err := s.Search(ctx, "quick fox", opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n, _ := s.SearchCount(ctx, "quick fox", opts...); !reflect.DeepEqual(idsOf(found), phrases[1:3]) || n != len(phrases) {
+		t.Errorf("paged phrase found %v, counted %d, want %v of %d", idsOf(found), n, phrases[1:3], len(phrases))
+	}
+}
+
+func TestRandom(t *testing.T) {
+	s := newTestService(t)
+	ctx := context.Background()
+	if _, err := s.Random(ctx); !errors.Is(err, ErrNotFound) {
+		t.Errorf("got %v from an empty store, want ErrNotFound", err)
+	}
+	seeded := map[string]bool{}
+	for i := 0; i < 3; i++ {
+		seeded[mustCreate(t, s, Article{Title: "a"}).ID] = true
+	}
+	for i := 0; i < 10; i++ {
+		a, err := s.Random(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !seeded[a.ID] {
+			t.Errorf("got article %q, not one of %v", a.ID, seeded)
+		}
+	}
+}
+
+func TestPageLimitsPerRoute(t *testing.T) {
+	s := newTestService(t, WithListPageLimits(PageLimits{Default: 2, Max: 3}), WithSearchPageLimits(PageLimits{Default: 1}))
+	h := s.RESTful()
+	for i := 0; i < 5; i++ {
+		mustCreate(t, s, Article{Title: "match"})
+	}
+	for _, tc := range []struct {
+		target string
+		want   int
+	}{
+		{"/list", 2}, {"/list?limit=10", 3}, {"/list?limit=1", 1},
+		{"/search?q=match", 1}, {"/search?q=match&limit=4", 4},
+	} {
+		var articles []Article
+		decode(t, serve(h, "GET", tc.target, ""), &articles)
+		if len(articles) != tc.want {
+			t.Errorf("%s got %d articles, want %d", tc.target, len(articles), tc.want)
+		}
+	}
+}
+
+func TestSearchRoute(t *testing.T) {
+	s := newTestService(t)
+	h := s.RESTful()
+	for _, title := range []string{"go one", "go two", "go three", "rust"} {
+		mustCreate(t, s, Article{Title: title})
+	}
+	rec := serve(h, "GET", "/search?q=go&limit=2", "")
+	var articles []Article
+	decode(t, rec, &articles)
+	if len(articles) != 2 || rec.Header().Get("X-Total-Count") != "3" {
+		t.Errorf("got %d articles, X-Total-Count %q", len(articles), rec.Header().Get("X-Total-Count"))
+	}
+	if !strings.Contains(rec.Header().Get("Link"), `rel="next"`) {
+		t.Errorf("got Link %q, want a next page", rec.Header().Get("Link"))
+	}
+	rec = serve(h, "GET", "/search?q=_", "")
+	if rec.Header().Get("X-Total-Count") != "0" || strings.TrimSpace(rec.Body.String()) != "[]" {
+		t.Errorf("an underscore matched %s, X-Total-Count %q", rec.Body, rec.Header().Get("X-Total-Count"))
+	}
+	if rec := serve(h, "GET", "/search?q=go&mode=fuzzy", ""); rec.Code != http.StatusBadRequest {
+		t.Errorf("unknown mode got %d, want 400", rec.Code)
+	}
+	rec = serve(h, "GET", "/search?q=GO%20TWO&ci=true&mode=phrase", "")
+	decode(t, rec, &articles)
+	if len(articles) != 1 || articles[0].Title != "go two" {
+		t.Errorf("case-insensitive phrase got %s", rec.Body)
+	}
+}
+
+func TestEmptyLists(t *testing.T) {
+	s := newTestService(t)
+	h := s.RESTful()
+	for _, target := range []string{"/list", "/search?q=nothing", "/list?author=nobody", "/list/ids", "/authors", "/trending"} {
+		rec := serve(h, "GET", target, "")
+		if rec.Code != http.StatusOK || strings.TrimSpace(rec.Body.String()) != "[]" {
+			t.Errorf("%s got %d %q, want []", target, rec.Code, rec.Body)
+		}
+	}
+	if rec := serve(h, "GET", "/sync", ""); strings.TrimSpace(rec.Body.String()) != `{"changed":[],"deleted":[]}` {
+		t.Errorf("/sync got %q", rec.Body)
+	}
+	mustCreate(t, s, Article{Title: "a"})
+	if rec := serve(h, "GET", "/list?author=nobody", ""); strings.TrimSpace(rec.Body.String()) != "[]" {
+		t.Errorf("empty filtered list got %q", rec.Body)
+	}
+}
+
+func TestCreateRoute(t *testing.T) {
+	s := newTestService(t)
+	h := s.RESTful()
+	for _, ct := range []string{"application/json", "application/json; charset=utf-8"} {
+		rec := serve(h, "POST", "/article", `{"title":"t","content":"c"}`, "Content-Type", ct)
+		if rec.Code != http.StatusCreated {
+			t.Fatalf("%s: got %d %s", ct, rec.Code, rec.Body)
+		}
+		var created Article
+		decode(t, rec, &created)
+		if created.ID == "" || created.Title != "t" {
+			t.Errorf("201 body %s lacks the new article", rec.Body)
+		}
+		if loc := rec.Header().Get("Location"); loc != "/article/"+created.ID {
+			t.Errorf("got Location %q", loc)
+		}
+	}
+	rec := serve(h, "POST", "/article", `title=t`, "Content-Type", "text/plain")
+	if rec.Code != http.StatusUnsupportedMediaType {
+		t.Errorf("text/plain got %d, want 415", rec.Code)
+	}
+}
+
+func TestCreateRouteMethods(t *testing.T) {
+	s := newTestService(t)
+	h := s.RESTful()
+	for _, method := range []string{"GET", "PUT", "DELETE"} {
+		rec := serve(h, method, "/article", "")
+		if rec.Code != http.StatusMethodNotAllowed || errorOf(t, rec).Code != "method_not_allowed" {
+			t.Errorf("%s got %d %s, want 405", method, rec.Code, rec.Body)
+		}
+	}
+	if rec := serve(h, "PUT", "/article", `{"title":"a"}`); rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("PUT with an article got %d, want 405", rec.Code)
+	}
+	if n, _ := s.Count(context.Background()); n != 0 {
+		t.Errorf("got %d articles, want none", n)
+	}
+}
+
+func TestCreateRouteDecodeErrors(t *testing.T) {
+	h := newTestService(t).RESTful()
+	for body, want := range map[string]string{
+		`{"title": 5}`:     `field "title" should be string, got number`,
+		`{"title" "t"}`:    `malformed json at byte offset`,
+		`{"title": "t"`:    `unexpected end of body`,
+		`["not", "an", 1]`: `wrong json type, got array`,
+	} {
+		rec := serve(h, "POST", "/article", body)
+		if rec.Code != http.StatusBadRequest || !strings.Contains(errorOf(t, rec).Message, want) {
+			t.Errorf("%s: got %d %s, want 400 with %q", body, rec.Code, rec.Body, want)
+		}
+	}
+}
+
+func TestReadTxWithoutReadOnlySupport(t *testing.T) {
+	// ramsql can't begin read-only transactions, nor run Prepare, so the table is its own.
+	db, err := sql.Open("ramsql", t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	for _, stat := range []string{
+		`CREATE TABLE articles (id BIGSERIAL PRIMARY KEY, title TEXT, description TEXT, content TEXT, author TEXT, status TEXT, position INT, updated_at TIMESTAMP);`,
+		`INSERT INTO articles (title, description, content, author, status, position, updated_at) VALUES ('a', '', '', '', '', 0, '2024-01-01');`,
+	} {
+		if _, err := db.Exec(stat); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s := New(db)
+	ctx := context.Background()
+	if a, err := s.Random(ctx); err != nil || a.Title != "a" {
+		t.Errorf("Random got %+v, %v", a, err)
+	}
+	err = s.WithReadTx(ctx, func(st ArticleStore) error {
+		_, err := st.Count(ctx)
+		return err
+	})
+	if err != nil {
+		t.Errorf("WithReadTx got %v", err)
+	}
+}
+
+func TestReorder(t *testing.T) {
+	s := newTestService(t)
+	ctx := context.Background()
+	for i := 0; i < 3; i++ {
+		mustCreate(t, s, Article{Title: "a"})
+	}
+	if err := s.Reorder(ctx, []string{"3", "1", "2"}); err != nil {
+		t.Fatal(err)
+	}
+	got, err := s.List(ctx, SortBy("position"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(idsOf(got), []string{"3", "1", "2"}) {
+		t.Errorf("got %v, want [3 1 2]", idsOf(got))
+	}
+	if err := s.Reorder(ctx, []string{"1", "404", "3"}); !errors.Is(err, ErrNotFound) {
+		t.Errorf("got %v, want ErrNotFound", err)
+	}
+	got, _ = s.List(ctx, SortBy("position"))
+	if !reflect.DeepEqual(idsOf(got), []string{"3", "1", "2"}) {
+		t.Errorf("failed reorder changed the order to %v", idsOf(got))
+	}
+
+	// Articles left out follow in the order they were in, and new ones come last.
+	if err := s.Reorder(ctx, []string{"2"}); err != nil {
+		t.Fatal(err)
+	}
+	mustCreate(t, s, Article{Title: "new"})
+	got, _ = s.List(ctx, SortBy("position"))
+	if !reflect.DeepEqual(idsOf(got), []string{"2", "3", "1", "4"}) {
+		t.Errorf("got %v, want [2 3 1 4]", idsOf(got))
+	}
+	for i, a := range got {
+		if a.Position != i+1 {
+			t.Errorf("article %s at position %d, want %d", a.ID, a.Position, i+1)
+		}
+	}
+}
+
+func TestReplaceAll(t *testing.T) {
+	s := newTestService(t)
+	ctx := context.Background()
+	mustCreate(t, s, Article{Title: "old"})
+	mustCreate(t, s, Article{Title: "old"})
+	if err := s.ReplaceAll(ctx, []Article{{Title: "new"}, {ID: "10", Title: "new"}}); err != nil {
+		t.Fatal(err)
+	}
+	all, _ := s.List(ctx)
+	if len(all) != 2 {
+		t.Fatalf("got %d articles, want 2", len(all))
+	}
+	for _, a := range all {
+		if a.Title != "new" {
+			t.Errorf("old article left: %+v", a)
+		}
+	}
+	if _, err := s.Get(ctx, "10"); err != nil {
+		t.Errorf("article given an id can't be read by it: %v", err)
+	}
+}
+
+func TestReplaceAllTakenIDs(t *testing.T) {
+	logTo, logs := withTestLogger()
+	s := newTestService(t, logTo, WithSlowQueryThreshold(time.Nanosecond))
+	ctx := context.Background()
+	// The generated id of the first item would be 1, were the second not stored first.
+	if err := s.ReplaceAll(ctx, []Article{{Title: "a"}, {ID: "1", Title: "b"}}); err != nil {
+		t.Fatal(err)
+	}
+	if a, err := s.Get(ctx, "1"); err != nil || a.Title != "b" {
+		t.Errorf("got %+v, %v, want the article given id 1", a, err)
+	}
+
+	err := s.ReplaceAll(ctx, []Article{{ID: "7", Title: "a"}, {ID: "7", Title: "b"}})
+	if !errors.Is(err, ErrAlreadyExists) {
+		t.Errorf("got %v, want ErrAlreadyExists", err)
+	}
+	// No insert may fail, as that aborts the transaction on PostgreSQL.
+	inserts := 0
+	for _, rec := range logs.recordsOf(t, "slow query") {
+		if strings.HasSuffix(rec["op"].(string), "CreateWithID") && strings.HasPrefix(rec["query"].(string), "INSERT") {
+			inserts++
+		}
+	}
+	if inserts != 2 {
+		t.Errorf("CreateWithID ran %d inserts, want 2, one for id 1 and one for the first id 7", inserts)
+	}
+
+	var invalid *ValidationError
+	err = s.ReplaceAll(ctx, []Article{{Title: "a"}, {ID: "abc", Title: "b"}})
+	if !errors.As(err, &invalid) || invalid.Fields["1.id"] == "" {
+		t.Errorf("got %v, want a ValidationError for 1.id", err)
+	}
+	// Archived articles are not replaced, and keep their ids.
+	if err := s.Archive(ctx, "1"); err != nil {
+		t.Fatal(err)
+	}
+	err = s.ReplaceAll(ctx, []Article{{ID: "1", Title: "c"}})
+	if !errors.Is(err, ErrAlreadyExists) || !strings.HasPrefix(err.Error(), "article 0:") {
+		t.Errorf("got %v, want ErrAlreadyExists for article 0", err)
+	}
+	if err := s.Unarchive(ctx, "1"); err != nil {
+		t.Errorf("unarchive got %v", err)
+	}
+}
+
+func TestReplaceAllDropsViews(t *testing.T) {
+	s := newTestService(t)
+	ctx := context.Background()
+	a := mustCreate(t, s, Article{Title: "a"})
+	if _, err := s.IncrementViews(ctx, a.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.ReplaceAll(ctx, []Article{{ID: a.ID, Title: "new"}}); err != nil {
+		t.Fatal(err)
+	}
+	var n int
+	if err := s.DB.QueryRow(`SELECT COUNT(*) FROM article_views;`).Scan(&n); err != nil || n != 0 {
+		t.Errorf("got %d views left, %v", n, err)
+	}
+}
+
+func TestReplaceAllTombstones(t *testing.T) {
+	s := newTestService(t, WithIDGenerator(RandomUUID))
+	ctx := context.Background()
+	kept := mustCreate(t, s, Article{Title: "kept"})
+	gone := mustCreate(t, s, Article{Title: "gone"})
+	from := time.Now()
+	if err := s.ReplaceAll(ctx, []Article{{ID: kept.ID, Title: "kept"}, {Title: "new"}}); err != nil {
+		t.Fatal(err)
+	}
+	deleted, err := s.DeletedBetween(ctx, from, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(deleted) != 1 || deleted[0].ID != gone.ID {
+		t.Errorf("got tombstones %+v, want only %s", deleted, gone.ID)
+	}
+}
+
+func TestReplaceAllTombstonesReusedIDs(t *testing.T) {
+	s := newTestService(t)
+	ctx := context.Background()
+	old := []string{mustCreate(t, s, Article{Title: "old"}).ID, mustCreate(t, s, Article{Title: "old"}).ID}
+	from := time.Now()
+	// Generated ids may be those just deleted, depending on the database.
+	if err := s.ReplaceAll(ctx, []Article{{Title: "new"}}); err != nil {
+		t.Fatal(err)
+	}
+	ids, _ := s.ListIDs(ctx, QueryFilter{})
+	deleted, err := s.DeletedBetween(ctx, from, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range old {
+		stored, tombstoned := false, false
+		for _, storedID := range ids {
+			stored = stored || storedID == id
+		}
+		for _, d := range deleted {
+			tombstoned = tombstoned || d.ID == id
+		}
+		if stored == tombstoned {
+			t.Errorf("article %s is stored %t and recorded as deleted %t", id, stored, tombstoned)
+		}
+	}
+}
+
+func TestReplaceAllRollsBack(t *testing.T) {
+	s := newTestService(t, WithFieldLimits(FieldLimits{Title: 3}))
+	ctx := context.Background()
+	mustCreate(t, s, Article{Title: "old"})
+	err := s.ReplaceAll(ctx, []Article{{Title: "new"}, {Title: "too long"}})
+	var invalid *ValidationError
+	if !errors.As(err, &invalid) || invalid.Fields["1.title"] == "" {
+		t.Fatalf("got %v, want a ValidationError on 1.title", err)
+	}
+	all, _ := s.List(ctx)
+	if len(all) != 1 || all[0].Title != "old" {
+		t.Errorf("failed replace left %+v", all)
+	}
+}
+
+func TestNeighbors(t *testing.T) {
+	s := newTestService(t)
+	ctx := context.Background()
+	for i := 0; i < 3; i++ {
+		mustCreate(t, s, Article{Title: "a"})
+	}
+	id := func(a *Article) string {
+		if a == nil {
+			return ""
+		}
+		return a.ID
+	}
+	for _, tc := range []struct{ id, prev, next string }{
+		{"1", "", "2"},
+		{"2", "1", "3"},
+		{"3", "2", ""},
+	} {
+		prev, next, err := s.Neighbors(ctx, tc.id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if id(prev) != tc.prev || id(next) != tc.next {
+			t.Errorf("neighbors of %s: got %q and %q, want %q and %q", tc.id, id(prev), id(next), tc.prev, tc.next)
+		}
+	}
+	if _, _, err := s.Neighbors(ctx, "404"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("got %v, want ErrNotFound", err)
+	}
+}
+
+func TestArchive(t *testing.T) {
+	s := newTestService(t)
+	ctx := context.Background()
+	a := mustCreate(t, s, Article{Title: "a", Content: "kept"})
+	if err := s.Archive(ctx, a.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Get(ctx, a.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("archived article still found: %v", err)
+	}
+	if err := s.Archive(ctx, a.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("archiving twice got %v, want ErrNotFound", err)
+	}
+	if err := s.Unarchive(ctx, a.ID); err != nil {
+		t.Fatal(err)
+	}
+	got, err := s.Get(ctx, a.ID)
+	if err != nil || got.Content != "kept" {
+		t.Errorf("restored %+v, %v", got, err)
+	}
+	if err := s.Unarchive(ctx, a.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("restoring twice got %v, want ErrNotFound", err)
+	}
+
+	// A row written around the service may still take the id of an archived article.
+	if err := s.Archive(ctx, a.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.DB.Exec(`INSERT INTO articles (`+articleColumns+`) VALUES (?, 'other', '', '', '', '', 0, ?);`, a.ID, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Unarchive(ctx, a.ID); !errors.Is(err, ErrAlreadyExists) {
+		t.Errorf("restoring over another article got %v, want ErrAlreadyExists", err)
+	}
+	if got, _ := s.Get(ctx, a.ID); got == nil || got.Title != "other" {
+		t.Errorf("restoring over another article left %+v", got)
+	}
+}
+
+func TestArchiveTombstones(t *testing.T) {
+	s := newTestService(t)
+	ctx := context.Background()
+	a := mustCreate(t, s, Article{Title: "a"})
+	since := time.Now()
+	if err := s.Archive(ctx, a.ID); err != nil {
+		t.Fatal(err)
+	}
+	deleted, err := s.DeletedSince(ctx, since)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(deleted) != 1 || deleted[0].ID != a.ID {
+		t.Errorf("after archiving got tombstones %+v, want %s", deleted, a.ID)
+	}
+	if err := s.Unarchive(ctx, a.ID); err != nil {
+		t.Fatal(err)
+	}
+	// Restored, it is reported changed only.
+	if deleted, _ := s.DeletedSince(ctx, since); len(deleted) != 0 {
+		t.Errorf("after unarchiving got tombstones %+v", deleted)
+	}
+	if changed, _ := s.ModifiedSince(ctx, since); len(changed) != 1 || changed[0].ID != a.ID {
+		t.Errorf("after unarchiving got changed %v, want %s", idsOf(changed), a.ID)
+	}
+}
+
+func TestAuthors(t *testing.T) {
+	s := newTestService(t)
+	for _, author := range []string{"cy", "ann", "", "bob", "ann"} {
+		mustCreate(t, s, Article{Title: "a", Author: author})
+	}
+	got, err := s.Authors(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"ann", "bob", "cy"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestCountByStatus(t *testing.T) {
+	s := newTestService(t)
+	for _, status := range []string{"draft", "draft", "", "review"} {
+		mustCreate(t, s, Article{Title: "a", Status: status})
+	}
+	got, err := s.CountByStatus(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string]int{"draft": 2, "published": 0, "": 1, "review": 1}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestCount(t *testing.T) {
+	s := newTestService(t)
+	ctx := context.Background()
+	for i := 0; i < 3; i++ {
+		mustCreate(t, s, Article{Title: "a"})
+	}
+	if n, err := s.Count(ctx); err != nil || n != 3 {
+		t.Errorf("Count got %d, %v", n, err)
+	}
+	// SQLite has no pg_class, so the estimate falls back to the exact count.
+	if n, exact, err := s.EstimateCount(ctx); err != nil || n != 3 || !exact {
+		t.Errorf("EstimateCount got %d, %t, %v", n, exact, err)
+	}
+}
+
+func TestModifiedSince(t *testing.T) {
+	s := newTestService(t)
+	ctx := context.Background()
+	a := mustCreate(t, s, Article{Title: "a"})
+	mustCreate(t, s, Article{Title: "unchanged"})
+	since := time.Now()
+	c := mustCreate(t, s, Article{Title: "c"})
+	if err := s.Touch(ctx, a.ID); err != nil {
+		t.Fatal(err)
+	}
+	got, err := s.ModifiedSince(ctx, since)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{c.ID, a.ID}; !reflect.DeepEqual(idsOf(got), want) {
+		t.Errorf("got %v, want %v", idsOf(got), want)
+	}
+	if all, _ := s.ModifiedSince(ctx, time.Time{}); len(all) != 3 {
+		t.Errorf("got %d articles since the zero time, want all 3", len(all))
+	}
+}
+
+func TestDeletedSince(t *testing.T) {
+	s := newTestService(t)
+	ctx := context.Background()
+	before := mustCreate(t, s, Article{Title: "before"})
+	if err := s.Delete(ctx, before.ID); err != nil {
+		t.Fatal(err)
+	}
+	since := time.Now()
+	for _, title := range []string{"first", "second"} {
+		if err := s.Delete(ctx, mustCreate(t, s, Article{Title: title}).ID); err != nil {
+			t.Fatal(err)
+		}
+	}
+	deleted, err := s.DeletedSince(ctx, since)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(deleted) != 2 || deleted[0].ID != "2" || deleted[1].ID != "3" {
+		t.Errorf("got %+v, want 2 then 3", deleted)
+	}
+	if a := mustCreate(t, s, Article{Title: "new"}); a.ID != "4" {
+		t.Errorf("got id %s, want a fresh one", a.ID)
+	}
+}
+
+func TestChangedAndDeletedBetween(t *testing.T) {
+	s := newTestService(t)
+	ctx := context.Background()
+	before := mustCreate(t, s, Article{Title: "before"})
+	gone := mustCreate(t, s, Article{Title: "gone"})
+	from := time.Now()
+	inserted := mustCreate(t, s, Article{Title: "inserted"})
+	if err := s.Touch(ctx, before.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Delete(ctx, gone.ID); err != nil {
+		t.Fatal(err)
+	}
+	to := time.Now()
+	mustCreate(t, s, Article{Title: "after"})
+
+	changed, err := s.ChangedBetween(ctx, from, to)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{inserted.ID, before.ID}; !reflect.DeepEqual(idsOf(changed), want) {
+		t.Errorf("changed %v, want %v", idsOf(changed), want)
+	}
+	deleted, err := s.DeletedBetween(ctx, from, to)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(deleted) != 1 || deleted[0].ID != gone.ID || deleted[0].DeletedAt.Before(from) || deleted[0].DeletedAt.After(to) {
+		t.Errorf("deleted %+v, want %s within the window", deleted, gone.ID)
+	}
+}
+
+func TestWithReadTx(t *testing.T) {
+	s := newTestService(t)
+	ctx := context.Background()
+	mustCreate(t, s, Article{Title: "a"})
+	mustCreate(t, s, Article{Title: "b"})
+	err := s.WithReadTx(ctx, func(st ArticleStore) error {
+		n, err := st.Count(ctx)
+		if err != nil {
+			return err
+		}
+		all, err := st.List(ctx)
+		if err != nil {
+			return err
+		}
+		if len(all) != n {
+			t.Errorf("listed %d articles but counted %d within one transaction", len(all), n)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWithTx(t *testing.T) {
+	s := newTestService(t)
+	ctx := context.Background()
+	failed := errors.New("failed")
+	err := s.WithTx(ctx, func(st ArticleStore) error {
+		if _, err := st.Create(ctx, Article{Title: "rolled back"}); err != nil {
+			return err
+		}
+		return failed
+	})
+	if err != failed {
+		t.Fatalf("got %v, want the error of fn", err)
+	}
+	if n, _ := s.Count(ctx); n != 0 {
+		t.Errorf("rolled back transaction left %d articles", n)
+	}
+	err = s.WithTx(ctx, func(st ArticleStore) error {
+		for _, title := range []string{"a", "b"} {
+			if _, err := st.Create(ctx, Article{Title: title}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n, _ := s.Count(ctx); n != 2 {
+		t.Errorf("committed transaction left %d articles, want 2", n)
+	}
+}
+
+func TestVerifySchema(t *testing.T) {
+	s := newTestService(t)
+	if err := s.VerifySchema(context.Background()); err != nil {
+		t.Errorf("schema made by Prepare failed verification: %v", err)
+	}
+
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+	if _, err := db.Exec(`CREATE TABLE articles (id INTEGER PRIMARY KEY, title TEXT, description TEXT, content TEXT);`); err != nil {
+		t.Fatal(err)
+	}
+	err = New(db).VerifySchema(context.Background())
+	if !errors.Is(err, ErrSchemaMismatch) || !strings.Contains(err.Error(), "author") {
+		t.Errorf("got %v, want ErrSchemaMismatch naming the missing author column", err)
+	}
+}
+
+func TestCheckSchemaDrift(t *testing.T) {
+	s := newTestService(t)
+	ctx := context.Background()
+	if err := s.CheckSchemaDrift(ctx); err != nil {
+		t.Errorf("freshly prepared database drifted: %v", err)
+	}
+	if _, err := s.DB.Exec(`UPDATE schema_migrations SET version = version - 1;`); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.CheckSchemaDrift(ctx); !errors.Is(err, ErrSchemaDrift) {
+		t.Errorf("got %v for a database behind, want ErrSchemaDrift", err)
+	}
+}
+
+func TestSchemaIDStrategy(t *testing.T) {
+	ctx := context.Background()
+	serial := newTestService(t)
+	generated := newTestService(t, WithIDGenerator(RandomUUID))
+	for _, s := range []*ArticleService{serial, generated} {
+		if err := s.CheckSchemaDrift(ctx); err != nil {
+			t.Errorf("freshly prepared database drifted: %v", err)
+		}
+		if err := s.VerifySchema(ctx); err != nil {
+			t.Errorf("schema made by Prepare failed verification: %v", err)
+		}
+	}
+	// Each service on the database prepared for the other.
+	for _, s := range []*ArticleService{New(serial.DB, WithIDGenerator(RandomUUID)), New(generated.DB)} {
+		if err := s.CheckSchemaDrift(ctx); !errors.Is(err, ErrSchemaDrift) {
+			t.Errorf("got %v, want ErrSchemaDrift", err)
+		}
+		if err := s.VerifySchema(ctx); !errors.Is(err, ErrSchemaMismatch) {
+			t.Errorf("got %v, want ErrSchemaMismatch", err)
+		}
+	}
+	var info map[string]interface{}
+	decode(t, serve(New(generated.DB, WithAPIKey("admin-key", RoleAdmin)).RESTful(), "GET", "/debug/info", "", "X-API-Key", "admin-key"), &info)
+	if info["id_strategy"] != "serial" || info["applied_id_strategy"] != "generated" || info["schema_drift"] != true {
+		t.Errorf("got %v", info)
+	}
+}
+
+func TestStatementTimeout(t *testing.T) {
+	db, rec := openRecording(t)
+	s := New(db, WithStatementTimeout(time.Minute))
+	ctx := context.Background()
+	s.Touch(ctx, "1")
+	s.Get(ctx, "1")
+	s.Reorder(ctx, []string{"1"})
+	s.WithReadTx(ctx, func(st ArticleStore) error {
+		_, err := st.Count(ctx)
+		return err
+	})
+	statements := rec.recorded()
+	if len(statements) != 4 {
+		t.Fatalf("recorded %d statements, want one for each call", len(statements))
+	}
+	for _, st := range statements {
+		if left := time.Until(st.deadline); left <= 0 || left > time.Minute {
+			t.Errorf("%q ran with %s left, want up to a minute", st.query, left)
+		}
+	}
+
+	db, rec = openRecording(t)
+	New(db).Touch(ctx, "1")
+	if st := rec.recorded(); len(st) != 1 || !st[0].deadline.IsZero() {
+		t.Errorf("without a timeout recorded %+v, want one statement with no deadline", st)
+	}
+
+	// SQLite stops a statement running past the timeout.
+	slow := newTestService(t, WithStatementTimeout(50*time.Millisecond))
+	var n int
+	err := slow.db().QueryRowContext(ctx, `WITH RECURSIVE c(x) AS (SELECT 1 UNION ALL SELECT x + 1 FROM c) SELECT COUNT(*) FROM c;`).Scan(&n)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("an endless statement got %d, %v, want DeadlineExceeded", n, err)
+	}
+}
+
+func TestIncrementViewsConcurrently(t *testing.T) {
+	s := newTestService(t)
+	a := mustCreate(t, s, Article{Title: "a"})
+	const n = 20
+	counts := make([]int, n)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			v, err := s.IncrementViews(context.Background(), a.ID)
+			if err != nil {
+				t.Error(err)
+			}
+			counts[i] = v
+		}(i)
+	}
+	wg.Wait()
+	sort.Ints(counts)
+	for i, v := range counts {
+		if v != i+1 {
+			t.Fatalf("got counts %v, want each of 1 to %d once", counts, n)
+		}
+	}
+	if _, err := s.IncrementViews(context.Background(), "404"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("got %v, want ErrNotFound", err)
+	}
+}
+
+func TestTrendingWindow(t *testing.T) {
+	s := newTestService(t)
+	ctx := context.Background()
+	old := mustCreate(t, s, Article{Title: "old"})
+	recent := mustCreate(t, s, Article{Title: "recent"})
+	once := mustCreate(t, s, Article{Title: "once"})
+	mustCreate(t, s, Article{Title: "never"})
+	now := time.Now().UTC()
+	views := []struct {
+		id  string
+		ago time.Duration
+		n   int
+	}{{old.ID, 48 * time.Hour, 3}, {recent.ID, time.Hour, 2}, {once.ID, time.Hour, 1}}
+	for _, v := range views {
+		for i := 0; i < v.n; i++ {
+			if _, err := s.DB.Exec(`INSERT INTO article_views (article_id, viewed_at) VALUES (?, ?);`, v.id, now.Add(-v.ago)); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	for _, tc := range []struct {
+		window time.Duration
+		limit  int
+		want   []string
+	}{
+		{24 * time.Hour, 10, []string{recent.ID, once.ID}},
+		{72 * time.Hour, 10, []string{old.ID, recent.ID, once.ID}},
+		{72 * time.Hour, 1, []string{old.ID}},
+	} {
+		got, err := s.Trending(ctx, tc.window, tc.limit)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(idsOf(got), tc.want) {
+			t.Errorf("window %s, limit %d: got %v, want %v", tc.window, tc.limit, idsOf(got), tc.want)
+		}
+	}
+}
+
+func TestStats(t *testing.T) {
+	s := newTestService(t)
+	if st := s.Stats(); st.MaxOpenConnections != 1 {
+		t.Errorf("got %+v, want the pool of one connection", st)
+	}
+}
+
+func TestDelete(t *testing.T) {
+	s := newTestService(t)
+	ctx := context.Background()
+	a := mustCreate(t, s, Article{Title: "a"})
+	if err := s.Delete(ctx, a.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Get(ctx, a.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("deleted article still found: %v", err)
+	}
+	if err := s.Delete(ctx, a.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("deleting twice got %v, want ErrNotFound", err)
+	}
+}
+
+func TestTouch(t *testing.T) {
+	s := newTestService(t)
+	ctx := context.Background()
+	a := mustCreate(t, s, Article{Title: "a", Content: "c"})
+	if err := s.Touch(ctx, a.ID); err != nil {
+		t.Fatal(err)
+	}
+	got, _ := s.Get(ctx, a.ID)
+	if !got.UpdatedAt.After(a.UpdatedAt) || got.Title != a.Title || got.Content != a.Content {
+		t.Errorf("touched %+v into %+v", a, got)
+	}
+	if err := s.Touch(ctx, "404"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("got %v, want ErrNotFound", err)
+	}
+}
+
+func TestContentRoute(t *testing.T) {
+	s := newTestService(t)
+	h := s.RESTful()
+	a := mustCreate(t, s, Article{Title: "a", Content: "0123456789"})
+	rec := serve(h, "GET", "/article/"+a.ID+"/content", "")
+	if rec.Code != http.StatusOK || rec.Body.String() != "0123456789" || rec.Header().Get("Accept-Ranges") != "bytes" {
+		t.Errorf("full fetch got %d %q, Accept-Ranges %q", rec.Code, rec.Body, rec.Header().Get("Accept-Ranges"))
+	}
+	rec = serve(h, "GET", "/article/"+a.ID+"/content", "", "Range", "bytes=2-5")
+	if rec.Code != http.StatusPartialContent || rec.Body.String() != "2345" || rec.Header().Get("Content-Range") != "bytes 2-5/10" {
+		t.Errorf("ranged fetch got %d %q, Content-Range %q", rec.Code, rec.Body, rec.Header().Get("Content-Range"))
+	}
+	if rec := serve(h, "GET", "/article/404/content", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("got %d, want 404", rec.Code)
+	}
+}
+
+func TestArchiveRoutes(t *testing.T) {
+	s := newTestService(t)
+	h := s.RESTful()
+	a := mustCreate(t, s, Article{Title: "a"})
+	for _, tc := range []struct {
+		path   string
+		status int
+		found  bool
+	}{
+		{"/archive", http.StatusOK, false},
+		{"/archive", http.StatusNotFound, false},
+		{"/unarchive", http.StatusOK, true},
+		{"/unarchive", http.StatusNotFound, true},
+	} {
+		if rec := serve(h, "POST", "/article/"+a.ID+tc.path, ""); rec.Code != tc.status {
+			t.Errorf("%s got %d, want %d", tc.path, rec.Code, tc.status)
+		}
+		if found := serve(h, "GET", "/article/"+a.ID, "").Code == http.StatusOK; found != tc.found {
+			t.Errorf("after %s, found is %t", tc.path, found)
+		}
+	}
+}
+
+func TestTouchRoute(t *testing.T) {
+	s := newTestService(t)
+	h := s.RESTful()
+	a := mustCreate(t, s, Article{Title: "a"})
+	if rec := serve(h, "POST", "/article/"+a.ID+"/touch", ""); rec.Code != http.StatusOK {
+		t.Errorf("got %d", rec.Code)
+	}
+	if got, _ := s.Get(context.Background(), a.ID); !got.UpdatedAt.After(a.UpdatedAt) {
+		t.Errorf("updated_at not bumped")
+	}
+	if rec := serve(h, "POST", "/article/404/touch", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("got %d, want 404", rec.Code)
+	}
+	if rec := serve(h, "GET", "/article/"+a.ID+"/touch", ""); rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("GET got %d, want 405", rec.Code)
+	}
+}
+
+func TestCreateRouteInvalid(t *testing.T) {
+	h := newTestService(t, WithFieldLimits(FieldLimits{Title: 2, Content: 2})).RESTful()
+	rec := serve(h, "POST", "/article", `{"title":"long","content":"long"}`)
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("got %d, want 400", rec.Code)
+	}
+	e := errorOf(t, rec)
+	if e.Code != "invalid_article" || e.Fields["title"] == "" || e.Fields["content"] == "" {
+		t.Errorf("got %+v", e)
+	}
+}
+
+func TestArticleRoutes(t *testing.T) {
+	s := newTestService(t)
+	h := s.RESTful()
+	a := mustCreate(t, s, Article{Title: "a"})
+
+	rec := serve(h, "GET", "/article/"+a.ID, "")
+	var got Article
+	decode(t, rec, &got)
+	if rec.Code != http.StatusOK || got.ID != a.ID || rec.Header().Get("Cache-Control") != "no-store" {
+		t.Errorf("GET got %d %s, Cache-Control %q", rec.Code, rec.Body, rec.Header().Get("Cache-Control"))
+	}
+	if rec := serve(h, "GET", "/article/404", ""); rec.Code != http.StatusNotFound || errorOf(t, rec).Code != "not_found" {
+		t.Errorf("GET of a missing article got %d %s", rec.Code, rec.Body)
+	}
+	if rec := serve(h, "PATCH", "/article/"+a.ID, ""); rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("PATCH got %d, want 405", rec.Code)
+	}
+	if rec := serve(h, "DELETE", "/article/"+a.ID, ""); rec.Code != http.StatusOK {
+		t.Errorf("DELETE got %d %s", rec.Code, rec.Body)
+	}
+	if rec := serve(h, "DELETE", "/article/"+a.ID, ""); rec.Code != http.StatusNotFound {
+		t.Errorf("second DELETE got %d, want 404", rec.Code)
+	}
+}
+
+func TestDeleteRoute(t *testing.T) {
+	s := newTestService(t)
+	h := s.RESTful()
+	gone, kept := mustCreate(t, s, Article{Title: "gone"}), mustCreate(t, s, Article{Title: "kept"})
+	if rec := serve(h, "DELETE", "/article/"+gone.ID, ""); rec.Code != http.StatusOK {
+		t.Fatalf("got %d %s", rec.Code, rec.Body)
+	}
+	var left []Article
+	decode(t, serve(h, "GET", "/list", ""), &left)
+	if !reflect.DeepEqual(idsOf(left), []string{kept.ID}) {
+		t.Errorf("got %v left, want only %s", idsOf(left), kept.ID)
+	}
+	if rec := serve(h, "DELETE", "/article/404", ""); rec.Code != http.StatusNotFound || errorOf(t, rec).Code != "not_found" {
+		t.Errorf("unknown id got %d %s", rec.Code, rec.Body)
+	}
+
+	// Failures are reported, not taken for success.
+	if _, err := s.DB.Exec(`DROP TABLE articles;`); err != nil {
+		t.Fatal(err)
+	}
+	rec := serve(h, "DELETE", "/article/"+kept.ID, "")
+	if rec.Code != http.StatusInternalServerError || errorOf(t, rec).Message != "could not delete article" {
+		t.Errorf("failed delete got %d %s", rec.Code, rec.Body)
+	}
+}
+
+func TestPutCreatesOnly(t *testing.T) {
+	h := newTestService(t).RESTful()
+	if rec := serve(h, "PUT", "/article/7", `{"title":"seven"}`); rec.Code != http.StatusPreconditionRequired {
+		t.Errorf("PUT without If-None-Match got %d, want 428", rec.Code)
+	}
+	rec := serve(h, "PUT", "/article/7", `{"title":"seven"}`, "If-None-Match", "*")
+	var created Article
+	decode(t, rec, &created)
+	if rec.Code != http.StatusCreated || created.ID != "7" || rec.Header().Get("Location") != "/article/7" {
+		t.Errorf("PUT got %d %s, Location %q", rec.Code, rec.Body, rec.Header().Get("Location"))
+	}
+	if rec := serve(h, "PUT", "/article/7", `{"title":"again"}`, "If-None-Match", "*"); rec.Code != http.StatusConflict {
+		t.Errorf("PUT of a taken id got %d, want 409", rec.Code)
+	}
+	if rec := serve(h, "PUT", "/article/8", `title=eight`, "If-None-Match", "*", "Content-Type", "text/plain"); rec.Code != http.StatusBadRequest {
+		t.Errorf("PUT of text/plain got %d, want 400", rec.Code)
+	}
+	if rec := serve(h, "PUT", "/article/abc", `{"title":"abc"}`, "If-None-Match", "*"); rec.Code != http.StatusBadRequest || errorOf(t, rec).Fields["id"] == "" {
+		t.Errorf("PUT of a serial id that is no integer got %d %s, want 400", rec.Code, rec.Body)
+	}
+	serve(h, "POST", "/article/7/archive", "")
+	if rec := serve(h, "PUT", "/article/7", `{"title":"again"}`, "If-None-Match", "*"); rec.Code != http.StatusConflict {
+		t.Errorf("PUT of an archived id got %d, want 409", rec.Code)
+	}
+	if rec := serve(h, "POST", "/article/7/unarchive", ""); rec.Code != http.StatusOK {
+		t.Errorf("unarchive got %d %s", rec.Code, rec.Body)
+	}
+}
+
+func TestUnmatchedRoute(t *testing.T) {
+	h := newTestService(t).RESTful()
+	rec := serve(h, "GET", "/no/such/route", "")
+	if rec.Code != http.StatusNotFound || rec.Header().Get("Content-Type") != "application/json" {
+		t.Fatalf("got %d with Content-Type %q", rec.Code, rec.Header().Get("Content-Type"))
+	}
+	if e := errorOf(t, rec); e.Code != "not_found" || e.Message != "no such route" {
+		t.Errorf("got %+v", e)
+	}
+}
+
+func TestTrailingSlash(t *testing.T) {
+	s := newTestService(t)
+	h := s.RESTful()
+	mustCreate(t, s, Article{Title: "a", Author: "ann"})
+	for _, tc := range []struct{ method, path string }{
+		{"GET", "/list"}, {"GET", "/list/ids"}, {"GET", "/search"}, {"GET", "/count"}, {"GET", "/authors"},
+		{"GET", "/authors/ann/articles"}, {"GET", "/stats/status"}, {"GET", "/sync"}, {"GET", "/trending"},
+		{"GET", "/random"}, {"GET", "/openapi.json"}, {"GET", "/schema/article"}, {"GET", "/article/1"},
+		{"GET", "/article/1/stats"}, {"GET", "/article/1/neighbors"}, {"GET", "/article/1/content"},
+		{"POST", "/article/1/touch"}, {"POST", "/article/1/view"}, {"GET", "/article/404"},
+	} {
+		without := serve(h, tc.method, tc.path, "")
+		with := serve(h, tc.method, tc.path+"/", "")
+		if without.Code != with.Code || without.Code == http.StatusNotFound && tc.path != "/article/404" {
+			t.Errorf("%s %s got %d, and %d with a trailing slash", tc.method, tc.path, without.Code, with.Code)
+		}
+	}
+}
+
+func TestRegisterRoutes(t *testing.T) {
+	s := newTestService(t)
+	mustCreate(t, s, Article{Title: "a"})
+	r := mux.NewRouter()
+	r.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) { w.Write([]byte("ok")) })
+	s.RegisterRoutes(r.PathPrefix("/api").Subrouter())
+
+	if rec := serve(r, "GET", "/health", ""); rec.Code != http.StatusOK || rec.Body.String() != "ok" {
+		t.Errorf("existing route got %d %s", rec.Code, rec.Body)
+	}
+	rec := serve(r, "GET", "/api/list", "")
+	var articles []Article
+	decode(t, rec, &articles)
+	if rec.Code != http.StatusOK || len(articles) != 1 {
+		t.Errorf("registered route got %d %s", rec.Code, rec.Body)
+	}
+	if rec := serve(r, "POST", "/api/article", `{"title":"b"}`); rec.Header().Get("Location") != "/api/article/2" {
+		t.Errorf("got Location %q under the prefix", rec.Header().Get("Location"))
+	}
+}
+
+func TestListRouteLinks(t *testing.T) {
+	s := newTestService(t)
+	h := s.RESTful()
+	for i := 0; i < 5; i++ {
+		mustCreate(t, s, Article{Title: "a"})
+	}
+	for _, tc := range []struct {
+		offset int
+		ids    []string
+		rels   []string
+	}{
+		{0, []string{"1", "2"}, []string{`</list?limit=2&offset=0>; rel="first"`, `</list?limit=2&offset=2>; rel="next"`}},
+		{2, []string{"3", "4"}, []string{`</list?limit=2&offset=0>; rel="first"`, `</list?limit=2&offset=0>; rel="prev"`, `</list?limit=2&offset=4>; rel="next"`}},
+		{4, []string{"5"}, []string{`</list?limit=2&offset=0>; rel="first"`, `</list?limit=2&offset=2>; rel="prev"`}},
+	} {
+		rec := serve(h, "GET", "/list?limit=2&offset="+strconv.Itoa(tc.offset), "")
+		var articles []Article
+		decode(t, rec, &articles)
+		if !reflect.DeepEqual(idsOf(articles), tc.ids) {
+			t.Errorf("offset %d: got %v, want %v", tc.offset, idsOf(articles), tc.ids)
+		}
+		if got, want := rec.Header().Get("Link"), strings.Join(tc.rels, ", "); got != want {
+			t.Errorf("offset %d: got Link %q, want %q", tc.offset, got, want)
+		}
+	}
+	for _, q := range []string{"limit=0", "limit=x", "offset=-1", "sort=title", "updated_after=yesterday"} {
+		if rec := serve(h, "GET", "/list?"+q, ""); rec.Code != http.StatusBadRequest {
+			t.Errorf("%s got %d, want 400", q, rec.Code)
+		}
+	}
+}
+
+func TestGetManyRoute(t *testing.T) {
+	s := newTestService(t)
+	h := s.RESTful()
+	mustCreate(t, s, Article{Title: "a"})
+	mustCreate(t, s, Article{Title: "b"})
+
+	var aligned []*Article
+	decode(t, serve(h, "GET", "/articles?ids=2,404&ids=1&strict=true", ""), &aligned)
+	if len(aligned) != 3 || aligned[0].ID != "2" || aligned[1] != nil || aligned[2].ID != "1" {
+		t.Errorf("strict got %v", aligned)
+	}
+	var many []Article
+	decode(t, serve(h, "GET", "/articles?ids=2,404,1", ""), &many)
+	if !reflect.DeepEqual(idsOf(many), []string{"2", "1"}) {
+		t.Errorf("got %v, want [2 1]", idsOf(many))
+	}
+	if rec := serve(h, "GET", "/articles", ""); rec.Code != http.StatusBadRequest {
+		t.Errorf("no ids got %d, want 400", rec.Code)
+	}
+	tooMany := strings.Repeat("1,", maxGetMany) + "1"
+	if rec := serve(h, "GET", "/articles?ids="+tooMany, ""); rec.Code != http.StatusBadRequest {
+		t.Errorf("too many ids got %d, want 400", rec.Code)
+	}
+}
+
+func TestReplaceAllRoute(t *testing.T) {
+	s := newTestService(t, WithAPIKey("admin-key", RoleAdmin), WithAPIKey("reader-key", RoleReader))
+	h := s.RESTful()
+	mustCreate(t, s, Article{Title: "old"})
+	body := `[{"title":"a"},{"id":"5","title":"b"}]`
+	if rec := serve(h, "PUT", "/articles", body); rec.Code != http.StatusUnauthorized {
+		t.Errorf("anonymous got %d, want 401", rec.Code)
+	}
+	if rec := serve(h, "PUT", "/articles", body, "X-API-Key", "reader-key"); rec.Code != http.StatusForbidden {
+		t.Errorf("reader got %d, want 403", rec.Code)
+	}
+	if rec := serve(h, "PUT", "/articles", body, "X-API-Key", "admin-key"); rec.Code != http.StatusOK {
+		t.Fatalf("admin got %d %s", rec.Code, rec.Body)
+	}
+	if n, _ := s.Count(context.Background()); n != 2 {
+		t.Errorf("got %d articles, want 2", n)
+	}
+	rec := serve(h, "PUT", "/articles", `[{"title":"a"},{"id":"abc","title":"b"}]`, "X-API-Key", "admin-key")
+	if rec.Code != http.StatusBadRequest || errorOf(t, rec).Fields["1.id"] == "" {
+		t.Errorf("id that is no integer got %d %s, want 400 for 1.id", rec.Code, rec.Body)
+	}
+	serve(h, "POST", "/article/5/archive", "", "X-API-Key", "admin-key")
+	if rec := serve(h, "PUT", "/articles", `[{"id":"5","title":"b"}]`, "X-API-Key", "admin-key"); rec.Code != http.StatusConflict {
+		t.Errorf("id of an archived article got %d %s, want 409", rec.Code, rec.Body)
+	}
+}
+
+func TestReorderRoute(t *testing.T) {
+	s := newTestService(t)
+	h := s.RESTful()
+	for i := 0; i < 3; i++ {
+		mustCreate(t, s, Article{Title: "a"})
+	}
+	if rec := serve(h, "PUT", "/articles/order", `["2","3","1"]`); rec.Code != http.StatusOK {
+		t.Fatalf("got %d %s", rec.Code, rec.Body)
+	}
+	var articles []Article
+	decode(t, serve(h, "GET", "/list?sort=position", ""), &articles)
+	if !reflect.DeepEqual(idsOf(articles), []string{"2", "3", "1"}) {
+		t.Errorf("got %v, want [2 3 1]", idsOf(articles))
+	}
+	if rec := serve(h, "PUT", "/articles/order", `["404"]`); rec.Code != http.StatusNotFound {
+		t.Errorf("unknown id got %d, want 404", rec.Code)
+	}
+}
+
+func TestAuthorArticlesRoute(t *testing.T) {
+	s := newTestService(t)
+	h := s.RESTful()
+	for _, author := range []string{"ann", "bob", "ann", "ann"} {
+		mustCreate(t, s, Article{Title: "a", Author: author})
+	}
+	var ids []string
+	for offset := 0; offset < 4; offset += 2 {
+		rec := serve(h, "GET", "/authors/ann/articles?limit=2&offset="+strconv.Itoa(offset), "")
+		var page []Article
+		decode(t, rec, &page)
+		if rec.Header().Get("X-Total-Count") != "3" {
+			t.Errorf("got X-Total-Count %q, want 3", rec.Header().Get("X-Total-Count"))
+		}
+		if more := strings.Contains(rec.Header().Get("Link"), `rel="next"`); more != (offset == 0) {
+			t.Errorf("offset %d: got Link %q", offset, rec.Header().Get("Link"))
+		}
+		ids = append(ids, idsOf(page)...)
+	}
+	if !reflect.DeepEqual(ids, []string{"1", "3", "4"}) {
+		t.Errorf("paged through %v, want [1 3 4]", ids)
+	}
+}
+
+func TestCountRoutes(t *testing.T) {
+	s := newTestService(t)
+	h := s.RESTful()
+	for _, status := range []string{"draft", "published", "published"} {
+		mustCreate(t, s, Article{Title: "a", Status: status})
+	}
+	for _, target := range []string{"/count", "/count?approx=true"} {
+		var count struct {
+			Count int  `json:"count"`
+			Exact bool `json:"exact"`
+		}
+		decode(t, serve(h, "GET", target, ""), &count)
+		if count.Count != 3 || !count.Exact {
+			t.Errorf("%s got %+v", target, count)
+		}
+	}
+	var counts map[string]int
+	decode(t, serve(h, "GET", "/stats/status", ""), &counts)
+	if want := map[string]int{"draft": 1, "published": 2}; !reflect.DeepEqual(counts, want) {
+		t.Errorf("got %v, want %v", counts, want)
+	}
+}
+
+func TestViewAndTrendingRoutes(t *testing.T) {
+	s := newTestService(t)
+	h := s.RESTful()
+	mustCreate(t, s, Article{Title: "a"})
+	mustCreate(t, s, Article{Title: "b"})
+	for _, id := range []string{"2", "2", "1"} {
+		if rec := serve(h, "POST", "/article/"+id+"/view", ""); rec.Code != http.StatusOK {
+			t.Fatalf("view got %d %s", rec.Code, rec.Body)
+		}
+	}
+	var views struct {
+		Views int `json:"views"`
+	}
+	decode(t, serve(h, "POST", "/article/2/view", ""), &views)
+	if views.Views != 3 {
+		t.Errorf("got %d views, want 3", views.Views)
+	}
+	var trending []Article
+	decode(t, serve(h, "GET", "/trending?window=1h", ""), &trending)
+	if !reflect.DeepEqual(idsOf(trending), []string{"2", "1"}) {
+		t.Errorf("got %v, want [2 1]", idsOf(trending))
+	}
+	if rec := serve(h, "GET", "/trending?window=-1h", ""); rec.Code != http.StatusBadRequest {
+		t.Errorf("negative window got %d, want 400", rec.Code)
+	}
+	if rec := serve(h, "POST", "/article/404/view", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("view of a missing article got %d, want 404", rec.Code)
+	}
+}
+
+func TestNeighborsRoute(t *testing.T) {
+	s := newTestService(t)
+	h := s.RESTful()
+	for i := 0; i < 2; i++ {
+		mustCreate(t, s, Article{Title: "a"})
+	}
+	rec := serve(h, "GET", "/article/1/neighbors", "")
+	var neighbors map[string]*Article
+	decode(t, rec, &neighbors)
+	if rec.Code != http.StatusOK || neighbors["prev"] != nil || neighbors["next"] == nil || neighbors["next"].ID != "2" {
+		t.Errorf("got %d %s", rec.Code, rec.Body)
+	}
+	if rec := serve(h, "GET", "/article/404/neighbors", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("got %d, want 404", rec.Code)
+	}
+}
+
+func TestRandomRoute(t *testing.T) {
+	s := newTestService(t)
+	h := s.RESTful()
+	if rec := serve(h, "GET", "/random", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("empty store got %d, want 404", rec.Code)
+	}
+	a := mustCreate(t, s, Article{Title: "only"})
+	var got Article
+	if decode(t, serve(h, "GET", "/random", ""), &got); got.ID != a.ID {
+		t.Errorf("got %+v, want the only article", got)
+	}
+}
+
+func TestExportDeltaRoute(t *testing.T) {
+	s := newTestService(t)
+	h := s.RESTful()
+	ctx := context.Background()
+	updated := mustCreate(t, s, Article{Title: "updated"})
+	deleted := mustCreate(t, s, Article{Title: "deleted"})
+	archived := mustCreate(t, s, Article{Title: "archived"})
+	from := time.Now().UTC()
+	inserted := mustCreate(t, s, Article{Title: "inserted"})
+	s.Touch(ctx, updated.ID)
+	s.Delete(ctx, deleted.ID)
+	s.Archive(ctx, archived.ID)
+	to := time.Now().UTC()
+
+	rec := serve(h, "GET", "/export/delta?from="+from.Format(time.RFC3339Nano)+"&to="+to.Format(time.RFC3339Nano), "")
+	var delta struct {
+		Changed []Article   `json:"changed"`
+		Deleted []Tombstone `json:"deleted"`
+	}
+	decode(t, rec, &delta)
+	if want := []string{inserted.ID, updated.ID}; !reflect.DeepEqual(idsOf(delta.Changed), want) {
+		t.Errorf("changed %v, want %v", idsOf(delta.Changed), want)
+	}
+	if len(delta.Deleted) != 2 || delta.Deleted[0].ID != deleted.ID || delta.Deleted[1].ID != archived.ID {
+		t.Errorf("deleted %+v, want %s then %s", delta.Deleted, deleted.ID, archived.ID)
+	}
+	for _, q := range []string{"", "from=x&to=" + to.Format(time.RFC3339), "from=" + to.Format(time.RFC3339Nano) + "&to=" + from.Format(time.RFC3339Nano)} {
+		if rec := serve(h, "GET", "/export/delta?"+q, ""); rec.Code != http.StatusBadRequest {
+			t.Errorf("%q got %d, want 400", q, rec.Code)
+		}
+	}
+}
+
+func TestDebugRoutes(t *testing.T) {
+	s := newTestService(t, WithAPIKey("admin-key", RoleAdmin), WithAPIKey("reader-key", RoleReader))
+	h := s.RESTful()
+	defer func(v string) { Version = v }(Version)
+	Version = "1.2.3"
+
+	for _, path := range []string{"/debug/info", "/debug/dbstats", "/debug/routes"} {
+		if rec := serve(h, "GET", path, ""); rec.Code != http.StatusUnauthorized {
+			t.Errorf("anonymous %s got %d, want 401", path, rec.Code)
+		}
+		if rec := serve(h, "GET", path, "", "X-API-Key", "reader-key"); rec.Code != http.StatusForbidden {
+			t.Errorf("reader %s got %d, want 403", path, rec.Code)
+		}
+	}
+
+	var info map[string]interface{}
+	decode(t, serve(h, "GET", "/debug/info", "", "X-API-Key", "admin-key"), &info)
+	want := map[string]interface{}{
+		"version": "1.2.3", "schema_version": float64(schemaVersion), "applied_schema_version": float64(schemaVersion),
+		"id_strategy": "serial", "applied_id_strategy": "serial", "schema_drift": false, "driver": "*sqlite3.SQLiteDriver",
+	}
+	if !reflect.DeepEqual(info, want) {
+		t.Errorf("got %v, want %v", info, want)
+	}
+
+	var stats map[string]interface{}
+	decode(t, serve(h, "GET", "/debug/dbstats", "", "X-API-Key", "admin-key"), &stats)
+	for _, key := range []string{"max_open_connections", "open_connections", "in_use", "idle", "wait_count", "wait_duration_ms", "max_idle_closed", "max_idle_time_closed", "max_lifetime_closed"} {
+		if _, ok := stats[key]; !ok {
+			t.Errorf("dbstats lacks %s: %v", key, stats)
+		}
+	}
+	if stats["max_open_connections"] != float64(1) {
+		t.Errorf("got max_open_connections %v, want 1", stats["max_open_connections"])
+	}
+
+	var routes []routeInfo
+	decode(t, serve(h, "GET", "/debug/routes", "", "X-API-Key", "admin-key"), &routes)
+	wantMethods := map[string][]string{
+		"/article/{id}":         {"DELETE", "GET", "PUT"},
+		"/article":              {"POST"},
+		"/article/{id}/content": {"GET", "HEAD"},
+		"/debug/routes":         {"GET"},
+	}
+	for _, r := range routes {
+		if len(r.Methods) == 0 {
+			t.Errorf("%s lists no methods", r.Path)
+		}
+		if methods, ok := wantMethods[r.Path]; ok {
+			if !reflect.DeepEqual(r.Methods, methods) {
+				t.Errorf("%s lists %v, want %v", r.Path, r.Methods, methods)
+			}
+			delete(wantMethods, r.Path)
+		}
+	}
+	if len(wantMethods) > 0 {
+		t.Errorf("routes lack %v: %+v", wantMethods, routes)
+	}
+}
+
+func TestStatsRoute(t *testing.T) {
+	s := newTestService(t)
+	h := s.RESTful()
+	for _, tc := range []struct {
+		content string
+		want    ContentStats
+	}{
+		{"hello brave  new\tworld", ContentStats{Words: 4, Characters: 22}},
+		{"héllo wörld 日本語", ContentStats{Words: 3, Characters: 15}},
+		{"", ContentStats{}},
+	} {
+		a := mustCreate(t, s, Article{Title: "a", Content: tc.content})
+		if got := a.Stats(); got != tc.want {
+			t.Errorf("%q: Stats got %+v, want %+v", tc.content, got, tc.want)
+		}
+		var got ContentStats
+		if decode(t, serve(h, "GET", "/article/"+a.ID+"/stats", ""), &got); got != tc.want {
+			t.Errorf("%q: route got %+v, want %+v", tc.content, got, tc.want)
+		}
+	}
+	if rec := serve(h, "GET", "/article/404/stats", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("got %d, want 404", rec.Code)
+	}
+}
+
+func TestSyncRoute(t *testing.T) {
+	s := newTestService(t)
+	h := s.RESTful()
+	a := mustCreate(t, s, Article{Title: "a"})
+	gone := mustCreate(t, s, Article{Title: "gone"})
+	archived := mustCreate(t, s, Article{Title: "archived"})
+	rec := serve(h, "GET", "/sync", "")
+	since := rec.Header().Get("X-Sync-Time")
+	if _, err := time.Parse(time.RFC3339Nano, since); err != nil {
+		t.Fatalf("got X-Sync-Time %q", since)
+	}
+	b := mustCreate(t, s, Article{Title: "b"})
+	if err := s.Touch(context.Background(), a.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Delete(context.Background(), gone.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Archive(context.Background(), archived.ID); err != nil {
+		t.Fatal(err)
+	}
+	var delta struct {
+		Changed []Article   `json:"changed"`
+		Deleted []Tombstone `json:"deleted"`
+	}
+	rec = serve(h, "GET", "/sync?since="+since, "")
+	decode(t, rec, &delta)
+	if want := []string{b.ID, a.ID}; !reflect.DeepEqual(idsOf(delta.Changed), want) {
+		t.Errorf("changed %v, want %v", idsOf(delta.Changed), want)
+	}
+	if len(delta.Deleted) != 2 || delta.Deleted[0].ID != gone.ID || delta.Deleted[1].ID != archived.ID {
+		t.Errorf("deleted %+v, want %s then %s", delta.Deleted, gone.ID, archived.ID)
+	}
+	decode(t, serve(h, "GET", "/sync?since="+rec.Header().Get("X-Sync-Time"), ""), &delta)
+	if len(delta.Changed) != 0 || len(delta.Deleted) != 0 {
+		t.Errorf("synced again got %+v", delta)
+	}
+	if rec := serve(h, "GET", "/sync?since=yesterday", ""); rec.Code != http.StatusBadRequest {
+		t.Errorf("got %d, want 400", rec.Code)
+	}
+}
+
+func TestConcurrentRequests(t *testing.T) {
+	s := newTestService(t)
+	h := s.RESTful()
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if rec := serve(h, "POST", "/article", `{"title":"a"}`); rec.Code != http.StatusCreated {
+				t.Errorf("got %d %s", rec.Code, rec.Body)
+			}
+			serve(h, "GET", "/list", "")
+		}()
+	}
+	wg.Wait()
+	if n, _ := s.Count(context.Background()); n != 20 {
+		t.Errorf("got %d articles, want 20", n)
+	}
+}
+
+func TestSlowQueryLog(t *testing.T) {
+	logTo, logs := withTestLogger()
+	s := newTestService(t, logTo, WithSlowQueryThreshold(10*time.Millisecond))
+	mustCreate(t, s, Article{Title: "a"})
+	if n := len(logs.recordsOf(t, "slow query")); n != 0 {
+		t.Fatalf("got %d slow queries, want none", n)
+	}
+	// Statements waiting for the only connection are slow.
+	release := holdConn(t, s)
+	time.AfterFunc(30*time.Millisecond, release)
+	if _, err := s.Get(context.Background(), "1"); err != nil {
+		t.Fatal(err)
+	}
+	records := logs.recordsOf(t, "slow query")
+	if len(records) != 1 || !strings.HasPrefix(records[0]["query"].(string), "SELECT") || records[0]["duration"] == nil {
+		t.Fatalf("got %v", records)
+	}
+	if op := records[0]["op"]; op != "(*ArticleService).Get" {
+		t.Errorf("got op %q, want (*ArticleService).Get", op)
+	}
+	// Statements run in a transaction are logged under the method opening it.
+	logTo, logs = withTestLogger()
+	s = newTestService(t, logTo, WithSlowQueryThreshold(time.Nanosecond))
+	mustCreate(t, s, Article{Title: "a"})
+	if err := s.Reorder(context.Background(), []string{"1"}); err != nil {
+		t.Fatal(err)
+	}
+	records = logs.recordsOf(t, "slow query")
+	if op := records[len(records)-1]["op"]; op != "(*ArticleService).Reorder" {
+		t.Errorf("got op %q, want (*ArticleService).Reorder", op)
+	}
+
+	logTo, logs = withTestLogger()
+	s = newTestService(t, logTo)
+	release = holdConn(t, s)
+	time.AfterFunc(30*time.Millisecond, release)
+	s.Get(context.Background(), "1")
+	if n := len(logs.recordsOf(t, "slow query")); n != 0 {
+		t.Errorf("got %d slow queries without a threshold", n)
+	}
+}
+
+func TestLastModified(t *testing.T) {
+	s := newTestService(t)
+	ctx := context.Background()
+	if lm, err := s.LastModified(ctx); err != nil || !lm.IsZero() {
+		t.Fatalf("empty store last modified at %s, %v", lm, err)
+	}
+	a := mustCreate(t, s, Article{Title: "a"})
+	b := mustCreate(t, s, Article{Title: "b"})
+	last := b.UpdatedAt
+	for _, change := range []struct {
+		name string
+		fn   func() error
+	}{
+		{"touch", func() error { return s.Touch(ctx, a.ID) }},
+		{"reorder", func() error { return s.Reorder(ctx, []string{b.ID, a.ID}) }},
+		{"archive", func() error { return s.Archive(ctx, a.ID) }},
+		{"unarchive", func() error { return s.Unarchive(ctx, a.ID) }},
+		{"delete", func() error { return s.Delete(ctx, b.ID) }},
+		{"delete the last one", func() error { return s.Delete(ctx, a.ID) }},
+	} {
+		if err := change.fn(); err != nil {
+			t.Fatal(err)
+		}
+		lm, err := s.LastModified(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !lm.After(last) {
+			t.Errorf("%s left last modified at %s", change.name, lm)
+		}
+		last = lm
+	}
+}
+
+func TestListLastModified(t *testing.T) {
+	s := newTestService(t)
+	h := s.RESTful()
+	hourAgo := time.Now().UTC().Add(-time.Hour).Truncate(time.Second).Add(300 * time.Millisecond)
+	if _, err := s.DB.Exec(`INSERT INTO articles (`+articleColumns+`) VALUES ('1', 'a', '', '', '', '', 0, ?);`, hourAgo); err != nil {
+		t.Fatal(err)
+	}
+
+	fresh := serve(h, "GET", "/list", "")
+	lm := fresh.Header().Get("Last-Modified")
+	if want := hourAgo.Add(time.Second).Truncate(time.Second).Format(http.TimeFormat); fresh.Code != http.StatusOK || lm != want {
+		t.Fatalf("got %d, Last-Modified %q, want %q rounded up", fresh.Code, lm, want)
+	}
+	if rec := serve(h, "GET", "/list", "", "If-Modified-Since", lm); rec.Code != http.StatusNotModified || rec.Body.Len() != 0 {
+		t.Errorf("unchanged got %d %q, want 304", rec.Code, rec.Body)
+	}
+	if rec := serve(h, "GET", "/list?sort=title", "", "If-Modified-Since", lm); rec.Code != http.StatusBadRequest {
+		t.Errorf("invalid sort got %d, want 400 whatever If-Modified-Since", rec.Code)
+	}
+
+	before := time.Now()
+	if err := s.Delete(context.Background(), "1"); err != nil {
+		t.Fatal(err)
+	}
+	rec := serve(h, "GET", "/list", "", "If-Modified-Since", lm)
+	if rec.Code != http.StatusOK || strings.TrimSpace(rec.Body.String()) != "[]" {
+		t.Errorf("after a deletion got %d %q, want 200", rec.Code, rec.Body)
+	}
+	// Unless the second of the deletion ended meanwhile, it may still see more changes.
+	if got := rec.Header().Get("Last-Modified"); got != "" && time.Now().Truncate(time.Second).Equal(before.Truncate(time.Second)) {
+		t.Errorf("got Last-Modified %q for a second not over", got)
+	}
+	mustCreate(t, s, Article{Title: "b"})
+	if rec := serve(h, "GET", "/list", "", "If-Modified-Since", lm); rec.Code != http.StatusOK {
+		t.Errorf("after a creation got %d, want 200", rec.Code)
+	}
+}
@@ -0,0 +1,154 @@
+package service
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+// Role is what a caller may do, given by the API key it sends in the X-API-Key header.
+type Role string
+
+// Roles known to article service. Callers without an API key are RoleAnonymous.
+const (
+	RoleAnonymous Role = "anonymous"
+	RoleReader    Role = "reader"
+	RoleAdmin     Role = "admin"
+)
+
+// FieldPolicy lists, per role, the json fields of an article its callers must not see.
+type FieldPolicy map[Role][]string
+
+type roleKey struct{}
+
+// roleFrom returns the role of the caller set by authenticate.
+func roleFrom(ctx context.Context) Role {
+	if r, ok := ctx.Value(roleKey{}).(Role); ok {
+		return r
+	}
+	return RoleAnonymous
+}
+
+// authenticate resolves the role of the caller from its API key, rejecting unknown keys.
+func (s *ArticleService) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		role := RoleAnonymous
+		if key := r.Header.Get("X-API-Key"); key != "" {
+			var ok bool
+			if role, ok = s.lookupKey(key); !ok {
+				writeError(w, http.StatusUnauthorized, "unauthorized")
+				return
+			}
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), roleKey{}, role)))
+	})
+}
+
+// requireRole only lets callers acting with role through.
+func (s *ArticleService) requireRole(role Role, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch roleFrom(r.Context()) {
+		case role:
+			next.ServeHTTP(w, r)
+		case RoleAnonymous:
+			writeError(w, http.StatusUnauthorized, "unauthorized")
+		default:
+			writeError(w, http.StatusForbidden, "forbidden")
+		}
+	})
+}
+
+func (s *ArticleService) lookupKey(key string) (Role, bool) {
+	for k, role := range s.apiKeys {
+		if subtle.ConstantTimeCompare([]byte(k), []byte(key)) == 1 {
+			return role, true
+		}
+	}
+	return "", false
+}
+
+// hides tells whether the field policy hides field from the caller of r.
+func (s *ArticleService) hides(r *http.Request, field string) bool {
+	for _, f := range s.fieldPolicy[roleFrom(r.Context())] {
+		if f == field {
+			return true
+		}
+	}
+	return false
+}
+
+// view returns v as the caller of r may see it, with the computed fields asked by ?fields, without the
+// fields the field policy hides from its role, and with keys named as set by WithJSONNaming.
+// v is an article or a slice of them.
+func (s *ArticleService) view(r *http.Request, v interface{}) interface{} {
+	hidden := s.fieldPolicy[roleFrom(r.Context())]
+	computed := s.computedFields(r)
+	if len(hidden) == 0 && len(computed) == 0 && s.jsonNaming == SnakeCase {
+		return v
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return v
+	}
+	present := func(m map[string]json.RawMessage, raw []byte) map[string]json.RawMessage {
+		return s.jsonNaming.rename(omitFields(s.withComputed(m, raw, computed), hidden))
+	}
+	var one map[string]json.RawMessage
+	if json.Unmarshal(b, &one) == nil {
+		return present(one, b)
+	}
+	var many []json.RawMessage
+	if json.Unmarshal(b, &many) == nil {
+		views := make([]map[string]json.RawMessage, len(many))
+		for i, raw := range many {
+			if json.Unmarshal(raw, &views[i]) != nil {
+				return v
+			}
+			views[i] = present(views[i], raw)
+		}
+		return views
+	}
+	return v
+}
+
+// computedFields returns the names of the fields registered by WithComputedField that r asks for
+// with the fields query parameter, comma separated.
+func (s *ArticleService) computedFields(r *http.Request) []string {
+	if len(s.computed) == 0 {
+		return nil
+	}
+	var names []string
+	for _, f := range strings.Split(r.URL.Query().Get("fields"), ",") {
+		f = strings.TrimSpace(f)
+		if _, ok := s.computed[f]; ok {
+			names = append(names, f)
+		}
+	}
+	return names
+}
+
+// withComputed adds to m, the json of article raw, the computed fields named.
+func (s *ArticleService) withComputed(m map[string]json.RawMessage, raw []byte, names []string) map[string]json.RawMessage {
+	if len(names) == 0 || m == nil {
+		return m
+	}
+	var a Article
+	if json.Unmarshal(raw, &a) != nil {
+		return m
+	}
+	for _, name := range names {
+		if b, err := json.Marshal(s.computed[name](a)); err == nil {
+			m[name] = b
+		}
+	}
+	return m
+}
+
+func omitFields(m map[string]json.RawMessage, fields []string) map[string]json.RawMessage {
+	for _, f := range fields {
+		delete(m, f)
+	}
+	return m
+}
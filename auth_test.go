@@ -0,0 +1,111 @@
+package service
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestAPIKeys(t *testing.T) {
+	s := newTestService(t, WithAPIKey("admin-key", RoleAdmin), WithAPIKey("reader-key", RoleReader))
+	h := s.RESTful()
+	for key, want := range map[string]int{
+		"":           http.StatusOK,
+		"reader-key": http.StatusOK,
+		"admin-key":  http.StatusOK,
+		"wrong-key":  http.StatusUnauthorized,
+	} {
+		rec := serve(h, "GET", "/list", "", "X-API-Key", key)
+		if rec.Code != want {
+			t.Errorf("key %q got %d, want %d", key, rec.Code, want)
+		}
+	}
+	if e := errorOf(t, serve(h, "GET", "/list", "", "X-API-Key", "wrong-key")); e.Code != "unauthorized" {
+		t.Errorf("got %+v", e)
+	}
+}
+
+func TestFieldPolicy(t *testing.T) {
+	s := newTestService(t,
+		WithAPIKey("reader-key", RoleReader),
+		WithFieldPolicy(FieldPolicy{RoleAnonymous: {"content", "author"}}),
+	)
+	h := s.RESTful()
+	mustCreate(t, s, Article{Title: "a", Content: "secret", Author: "ann"})
+
+	for _, target := range []string{"/article/1", "/list", "/search?q=a", "/articles?ids=1"} {
+		anonymous := serve(h, "GET", target, "").Body.String()
+		if strings.Contains(anonymous, "secret") || strings.Contains(anonymous, "ann") || !strings.Contains(anonymous, `"title"`) {
+			t.Errorf("%s shows anonymous callers %s", target, anonymous)
+		}
+		reader := serve(h, "GET", target, "", "X-API-Key", "reader-key").Body.String()
+		if !strings.Contains(reader, "secret") || !strings.Contains(reader, "ann") {
+			t.Errorf("%s hides from readers %s", target, reader)
+		}
+	}
+	for _, path := range []string{"/article/1/content", "/article/1/stats"} {
+		if rec := serve(h, "GET", path, ""); rec.Code != http.StatusForbidden {
+			t.Errorf("%s of anonymous callers got %d, want 403", path, rec.Code)
+		}
+		if rec := serve(h, "GET", path, "", "X-API-Key", "reader-key"); rec.Code != http.StatusOK {
+			t.Errorf("%s of readers got %d %q", path, rec.Code, rec.Body)
+		}
+	}
+}
+
+func TestFieldPolicySearch(t *testing.T) {
+	s := newTestService(t,
+		WithAPIKey("reader-key", RoleReader),
+		WithFieldPolicy(FieldPolicy{RoleAnonymous: {"content"}, RoleReader: {"title", "description", "content"}}),
+	)
+	h := s.RESTful()
+	mustCreate(t, s, Article{Title: "open", Content: "secret"})
+	for _, target := range []string{"/search?q=secret", "/search?q=secret&mode=words", "/search?q=secret&mode=phrase&ci=true", "/list?q=secret", "/list/ids?q=secret"} {
+		if body := strings.TrimSpace(serve(h, "GET", target, "").Body.String()); body != "[]" {
+			t.Errorf("%s matched hidden content: %s", target, body)
+		}
+		if body := strings.TrimSpace(serve(h, "GET", strings.Replace(target, "secret", "open", 1), "").Body.String()); body == "[]" {
+			t.Errorf("%s didn't match the title", target)
+		}
+	}
+	if rec := serve(h, "GET", "/search?q=secret", ""); rec.Header().Get("X-Total-Count") != "0" {
+		t.Errorf("counted %s matches of hidden content", rec.Header().Get("X-Total-Count"))
+	}
+	if rec := serve(h, "GET", "/search/stream?q=secret", ""); rec.Body.Len() != 0 {
+		t.Errorf("streamed %q", rec.Body)
+	}
+	for _, target := range []string{"/search?q=open", "/list?q=open", "/search/stream?q=open"} {
+		if rec := serve(h, "GET", target, "", "X-API-Key", "reader-key"); rec.Code != http.StatusOK || strings.Contains(rec.Body.String(), "id") {
+			t.Errorf("%s matched when every searched field is hidden: %d %s", target, rec.Code, rec.Body)
+		}
+	}
+}
+
+func TestComputedFields(t *testing.T) {
+	s := newTestService(t, WithComputedField("excerpt", func(a Article) interface{} {
+		runes := []rune(a.Content)
+		if len(runes) > 5 {
+			runes = runes[:5]
+		}
+		return string(runes)
+	}))
+	h := s.RESTful()
+	mustCreate(t, s, Article{Title: "a", Content: "héllo world"})
+
+	var one map[string]interface{}
+	decode(t, serve(h, "GET", "/article/1?fields=excerpt", ""), &one)
+	if one["excerpt"] != "héllo" || one["content"] != "héllo world" {
+		t.Errorf("got %v", one)
+	}
+	var many []map[string]interface{}
+	decode(t, serve(h, "GET", "/list?fields=unknown,%20excerpt", ""), &many)
+	if len(many) != 1 || many[0]["excerpt"] != "héllo" {
+		t.Errorf("got %v", many)
+	}
+	if _, ok := many[0]["unknown"]; ok {
+		t.Errorf("unknown field computed: %v", many)
+	}
+	if body := serve(h, "GET", "/article/1", "").Body.String(); strings.Contains(body, "excerpt") {
+		t.Errorf("excerpt computed without being asked for: %s", body)
+	}
+}
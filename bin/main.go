@@ -3,25 +3,39 @@ package main
 import (
 	"context"
 	"database/sql"
+	"flag"
 	"log"
 	"net/http"
 
 	"example.com/service"
 
-	_ "github.com/proullon/ramsql/driver"
+	_ "github.com/mattn/go-sqlite3"
 )
 
 func main() {
+	allowDrift := flag.Bool("allow-schema-drift", false, "serve with a warning instead of exiting when the database schema is not the expected version")
+	flag.Parse()
 
-	db, err := sql.Open("ramsql", "somewhere")
+	db, err := sql.Open("sqlite3", ":memory:")
 	if err != nil {
 		log.Fatalf("could not open database: %s\n", err)
 	}
 	defer db.Close()
+	// Every connection to :memory: opens a database of its own, so keep to one.
+	db.SetMaxOpenConns(1)
 
-	svc := &service.ArticleService{DB: db}
+	svc := service.New(db)
 
 	svc.Prepare(context.TODO())
+	if err := svc.VerifySchema(context.TODO()); err != nil {
+		log.Fatalf("unexpected database schema: %s\n", err)
+	}
+	if err := svc.CheckSchemaDrift(context.TODO()); err != nil {
+		if !*allowDrift {
+			log.Fatalf("refusing to serve: %s\n", err)
+		}
+		log.Printf("WARNING: serving despite %s\n", err)
+	}
 	log.Println("start running service")
 
 	http.Handle("/api/", http.StripPrefix("/api", svc.RESTful()))
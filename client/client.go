@@ -0,0 +1,197 @@
+// Package client consumes the RESTful API of article service over HTTP.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"example.com/service"
+)
+
+// ErrNotFound is matched, with errors.Is, by the errors of requests answered not_found.
+// It is service.ErrNotFound, so callers test for missing articles alike whether local or remote.
+var ErrNotFound = service.ErrNotFound
+
+// Error is an error answered by article service, decoded from its JSON error envelope.
+type Error struct {
+	StatusCode int
+	Code       string
+	Message    string
+	// Fields tells why each invalid field was rejected, for invalid_article errors.
+	Fields map[string]string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("article service: %d %s: %s", e.StatusCode, e.Code, e.Message)
+}
+
+// Is lets errors.Is(err, ErrNotFound) hold for not_found errors.
+func (e *Error) Is(target error) bool {
+	return target == ErrNotFound && e.Code == "not_found"
+}
+
+// Client sends requests to article service.
+type Client struct {
+	base string
+	hc   *http.Client
+}
+
+// New returns a client for the article service at baseURL, such as "http://localhost:8080/api",
+// sending requests with hc, or http.DefaultClient if nil.
+func New(baseURL string, hc *http.Client) *Client {
+	if hc == nil {
+		hc = http.DefaultClient
+	}
+	return &Client{base: strings.TrimRight(baseURL, "/"), hc: hc}
+}
+
+// Create creates article a and returns it as stored, with its id.
+func (c *Client) Create(ctx context.Context, a service.Article) (*service.Article, error) {
+	var created service.Article
+	if err := c.do(ctx, http.MethodPost, "/article", a, &created); err != nil {
+		return nil, err
+	}
+	return &created, nil
+}
+
+// Get reads article id.
+func (c *Client) Get(ctx context.Context, id string) (*service.Article, error) {
+	var a service.Article
+	if err := c.do(ctx, http.MethodGet, "/article/"+url.PathEscape(id), nil, &a); err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+// maxBatch is the most ids the service reads at once, larger batches being sent in several requests.
+const maxBatch = 100
+
+// BatchGet reads the articles of ids in one request per hundred of them, in the order of ids,
+// leaving out those that don't exist.
+func (c *Client) BatchGet(ctx context.Context, ids []string) ([]service.Article, error) {
+	var articles []service.Article
+	for len(ids) > 0 {
+		n := len(ids)
+		if n > maxBatch {
+			n = maxBatch
+		}
+		var batch []service.Article
+		if err := c.do(ctx, http.MethodGet, "/articles?"+url.Values{"ids": ids[:n]}.Encode(), nil, &batch); err != nil {
+			return nil, err
+		}
+		articles = append(articles, batch...)
+		ids = ids[n:]
+	}
+	return articles, nil
+}
+
+// List reads the articles selected by f, as service.ArticleService.Query does.
+func (c *Client) List(ctx context.Context, f service.QueryFilter) ([]service.Article, error) {
+	q := url.Values{}
+	set := func(k, v string) {
+		if v != "" {
+			q.Set(k, v)
+		}
+	}
+	set("author", f.Author)
+	set("status", f.Status)
+	set("q", f.Text)
+	set("sort", f.Sort)
+	if !f.UpdatedAfter.IsZero() {
+		set("updated_after", f.UpdatedAfter.Format(time.RFC3339))
+	}
+	if !f.UpdatedBefore.IsZero() {
+		set("updated_before", f.UpdatedBefore.Format(time.RFC3339))
+	}
+	if f.Limit > 0 {
+		set("limit", strconv.Itoa(f.Limit))
+		set("offset", strconv.Itoa(f.Offset))
+	}
+	path := "/list"
+	if len(q) > 0 {
+		path += "?" + q.Encode()
+	}
+	var articles []service.Article
+	if err := c.do(ctx, http.MethodGet, path, nil, &articles); err != nil {
+		return nil, err
+	}
+	return articles, nil
+}
+
+// Search reads the articles whose title, description or content contains q.
+func (c *Client) Search(ctx context.Context, q string) ([]service.Article, error) {
+	var articles []service.Article
+	if err := c.do(ctx, http.MethodGet, "/search?"+url.Values{"q": {q}}.Encode(), nil, &articles); err != nil {
+		return nil, err
+	}
+	return articles, nil
+}
+
+// Delete deletes article id.
+func (c *Client) Delete(ctx context.Context, id string) error {
+	return c.do(ctx, http.MethodDelete, "/article/"+url.PathEscape(id), nil, nil)
+}
+
+// do sends in as json to path and decodes the response into out, unless nil.
+// Error responses are returned as *Error.
+func (c *Client) do(ctx context.Context, method, path string, in, out interface{}) error {
+	var body io.Reader
+	if in != nil {
+		b, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(b)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.base+path, body)
+	if err != nil {
+		return err
+	}
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := c.hc.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		return decodeError(resp)
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("article service: could not decode response: %w", err)
+	}
+	return nil
+}
+
+func decodeError(resp *http.Response) error {
+	e := &Error{StatusCode: resp.StatusCode}
+	var envelope struct {
+		Error struct {
+			Code    string            `json:"code"`
+			Message string            `json:"message"`
+			Fields  map[string]string `json:"fields"`
+		} `json:"error"`
+	}
+	if json.NewDecoder(resp.Body).Decode(&envelope) == nil && envelope.Error.Code != "" {
+		e.Code, e.Message, e.Fields = envelope.Error.Code, envelope.Error.Message, envelope.Error.Fields
+		return e
+	}
+	// Not from article service, maybe from a proxy in front of it.
+	e.Code = strings.ReplaceAll(strings.ToLower(http.StatusText(resp.StatusCode)), " ", "_")
+	e.Message = resp.Status
+	return e
+}
@@ -0,0 +1,199 @@
+package client
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"sync/atomic"
+	"testing"
+
+	"example.com/service"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// newTestClient returns a client of a service on a fresh in-memory SQLite database, and the number
+// of requests the service has been sent so far.
+func newTestClient(t *testing.T, opts ...service.Option) (*Client, *int64) {
+	t.Helper()
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Every connection to :memory: opens a database of its own, so keep to one.
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+	s := service.New(db, append([]service.Option{service.WithLogger(slog.New(slog.NewTextHandler(io.Discard, nil)))}, opts...)...)
+	s.Prepare(context.Background())
+	h := s.RESTful()
+	var requests int64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&requests, 1)
+		h.ServeHTTP(w, r)
+	}))
+	t.Cleanup(srv.Close)
+	return New(srv.URL, srv.Client()), &requests
+}
+
+// mustCreate creates an article per title and returns their ids.
+func mustCreate(t *testing.T, c *Client, titles ...string) []string {
+	t.Helper()
+	ids := make([]string, len(titles))
+	for i, title := range titles {
+		a, err := c.Create(context.Background(), service.Article{Title: title, Author: "ann"})
+		if err != nil {
+			t.Fatalf("could not create %q: %v", title, err)
+		}
+		ids[i] = a.ID
+	}
+	return ids
+}
+
+// idsOf returns the ids of articles, in order.
+func idsOf(articles []service.Article) []string {
+	ids := make([]string, len(articles))
+	for i, a := range articles {
+		ids[i] = a.ID
+	}
+	return ids
+}
+
+func TestCreateAndGet(t *testing.T) {
+	c, _ := newTestClient(t)
+	ctx := context.Background()
+	created, err := c.Create(ctx, service.Article{Title: "a", Content: "c"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if created.ID == "" || created.UpdatedAt.IsZero() {
+		t.Errorf("created %+v", created)
+	}
+	got, err := c.Get(ctx, created.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Title != "a" || got.Content != "c" {
+		t.Errorf("got %+v", got)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	c, _ := newTestClient(t, service.WithFieldLimits(service.FieldLimits{Title: 2}))
+	ctx := context.Background()
+	_, err := c.Get(ctx, "404")
+	var e *Error
+	if !errors.As(err, &e) || e.StatusCode != http.StatusNotFound || e.Code != "not_found" {
+		t.Errorf("got %v", err)
+	}
+	if !errors.Is(err, ErrNotFound) || !errors.Is(err, service.ErrNotFound) {
+		t.Errorf("%v isn't ErrNotFound", err)
+	}
+
+	_, err = c.Create(ctx, service.Article{Title: "long"})
+	if !errors.As(err, &e) || e.Code != "invalid_article" || e.Fields["title"] == "" {
+		t.Errorf("got %v", err)
+	}
+	if errors.Is(err, ErrNotFound) {
+		t.Errorf("%v is ErrNotFound", err)
+	}
+}
+
+func TestErrorsNotFromTheService(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "upstream down", http.StatusBadGateway)
+	}))
+	defer srv.Close()
+	_, err := New(srv.URL, nil).Get(context.Background(), "1")
+	var e *Error
+	if !errors.As(err, &e) || e.StatusCode != http.StatusBadGateway || e.Code != "bad_gateway" || e.Message != "502 Bad Gateway" {
+		t.Errorf("got %v", err)
+	}
+}
+
+func TestBatchGet(t *testing.T) {
+	c, requests := newTestClient(t)
+	titles := make([]string, 150)
+	for i := range titles {
+		titles[i] = "t" + strconv.Itoa(i)
+	}
+	ids := mustCreate(t, c, titles...)
+	// In reverse, with one unknown id left out.
+	var want []string
+	asked := []string{"404"}
+	for i := len(ids) - 1; i >= 0; i-- {
+		want = append(want, ids[i])
+		asked = append(asked, ids[i])
+	}
+	atomic.StoreInt64(requests, 0)
+	articles, err := c.BatchGet(context.Background(), asked)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(idsOf(articles), want) {
+		t.Errorf("got %v, want %v", idsOf(articles), want)
+	}
+	if n := atomic.LoadInt64(requests); n != 2 {
+		t.Errorf("sent %d requests, want 2", n)
+	}
+}
+
+func TestList(t *testing.T) {
+	c, _ := newTestClient(t)
+	ctx := context.Background()
+	ids := mustCreate(t, c, "go basics", "rust basics", "go generics")
+	for _, tc := range []struct {
+		f    service.QueryFilter
+		want []string
+	}{
+		{service.QueryFilter{}, ids},
+		{service.QueryFilter{Text: "go", Sort: "-id"}, []string{ids[2], ids[0]}},
+		{service.QueryFilter{Author: "ann", Limit: 1, Offset: 1}, []string{ids[1]}},
+		{service.QueryFilter{Author: "nobody"}, nil},
+	} {
+		articles, err := c.List(ctx, tc.f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := idsOf(articles); len(got) != len(tc.want) || len(got) > 0 && !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%+v: got %v, want %v", tc.f, got, tc.want)
+		}
+	}
+	if _, err := c.List(ctx, service.QueryFilter{Sort: "title"}); err == nil {
+		t.Errorf("invalid sort got no error")
+	}
+}
+
+func TestSearch(t *testing.T) {
+	c, _ := newTestClient(t)
+	ids := mustCreate(t, c, "go basics", "rust basics", "50% off")
+	articles, err := c.Search(context.Background(), "basics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(idsOf(articles), ids[:2]) {
+		t.Errorf("got %v, want %v", idsOf(articles), ids[:2])
+	}
+	if articles, _ := c.Search(context.Background(), "50% "); !reflect.DeepEqual(idsOf(articles), ids[2:]) {
+		t.Errorf("got %v, want %v", idsOf(articles), ids[2:])
+	}
+}
+
+func TestDelete(t *testing.T) {
+	c, _ := newTestClient(t)
+	ctx := context.Background()
+	id := mustCreate(t, c, "a")[0]
+	if err := c.Delete(ctx, id); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Get(ctx, id); !errors.Is(err, ErrNotFound) {
+		t.Errorf("deleted article still found: %v", err)
+	}
+	if err := c.Delete(ctx, id); !errors.Is(err, ErrNotFound) {
+		t.Errorf("deleting twice got %v, want ErrNotFound", err)
+	}
+}
@@ -0,0 +1,93 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"example.com/service"
+)
+
+// Loader coalesces the Gets made within a short window of each other into a single BatchGet,
+// saving a round trip per article when many are read at once, as while rendering a page.
+// It is safe for concurrent use.
+type Loader struct {
+	c    *Client
+	wait time.Duration
+
+	mu  sync.Mutex
+	cur *batch
+}
+
+// batch is the ids asked of a Loader within one window, and once done, what the service answered.
+type batch struct {
+	ids   []string
+	seen  map[string]bool
+	timer *time.Timer
+
+	done     chan struct{}
+	articles map[string]service.Article
+	err      error
+}
+
+// NewLoader returns a Loader sending the Gets made within wait of the first one, or as soon as they are
+// a hundred, as one BatchGet.
+func (c *Client) NewLoader(wait time.Duration) *Loader {
+	return &Loader{c: c, wait: wait}
+}
+
+// Get reads article id along with those asked by other calls within the same window.
+// The batch is sent regardless of ctx, which only bounds how long Get waits for it.
+func (l *Loader) Get(ctx context.Context, id string) (*service.Article, error) {
+	l.mu.Lock()
+	b := l.cur
+	if b == nil {
+		b = &batch{seen: make(map[string]bool), done: make(chan struct{})}
+		b.timer = time.AfterFunc(l.wait, func() {
+			l.mu.Lock()
+			if l.cur == b {
+				l.cur = nil
+			}
+			l.mu.Unlock()
+			l.send(b)
+		})
+		l.cur = b
+	}
+	if !b.seen[id] {
+		b.seen[id] = true
+		b.ids = append(b.ids, id)
+	}
+	if len(b.ids) == maxBatch {
+		l.cur = nil
+		// Unless it already fired, in which case it sends the batch itself.
+		if b.timer.Stop() {
+			go l.send(b)
+		}
+	}
+	l.mu.Unlock()
+
+	select {
+	case <-b.done:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	if b.err != nil {
+		return nil, b.err
+	}
+	a, ok := b.articles[id]
+	if !ok {
+		return nil, &Error{StatusCode: http.StatusNotFound, Code: "not_found", Message: "not found"}
+	}
+	return &a, nil
+}
+
+func (l *Loader) send(b *batch) {
+	articles, err := l.c.BatchGet(context.Background(), b.ids)
+	b.articles = make(map[string]service.Article, len(articles))
+	for _, a := range articles {
+		b.articles[a.ID] = a
+	}
+	b.err = err
+	close(b.done)
+}
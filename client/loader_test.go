@@ -0,0 +1,76 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestLoaderCoalesces(t *testing.T) {
+	c, requests := newTestClient(t)
+	ids := mustCreate(t, c, "a", "b", "c")
+	atomic.StoreInt64(requests, 0)
+	l := c.NewLoader(50 * time.Millisecond)
+
+	asked := append(ids, ids[0], "404")
+	errs := make([]error, len(asked))
+	titles := make([]string, len(asked))
+	var wg sync.WaitGroup
+	for i, id := range asked {
+		wg.Add(1)
+		go func(i int, id string) {
+			defer wg.Done()
+			a, err := l.Get(context.Background(), id)
+			if err == nil {
+				titles[i] = a.Title
+			}
+			errs[i] = err
+		}(i, id)
+	}
+	wg.Wait()
+
+	for i, want := range []string{"a", "b", "c", "a"} {
+		if errs[i] != nil || titles[i] != want {
+			t.Errorf("Get(%s) got %q, %v, want %q", asked[i], titles[i], errs[i], want)
+		}
+	}
+	if err := errs[len(asked)-1]; !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get of an unknown id got %v, want ErrNotFound", err)
+	}
+	if n := atomic.LoadInt64(requests); n != 1 {
+		t.Errorf("sent %d requests, want 1", n)
+	}
+}
+
+func TestLoaderSendsFullBatches(t *testing.T) {
+	c, requests := newTestClient(t)
+	atomic.StoreInt64(requests, 0)
+	// Long enough that only a full batch is sent before the test times out.
+	l := c.NewLoader(time.Hour)
+	var wg sync.WaitGroup
+	for i := 0; i < maxBatch; i++ {
+		wg.Add(1)
+		go func(id string) {
+			defer wg.Done()
+			l.Get(context.Background(), id)
+		}(strconv.Itoa(i + 1))
+	}
+	wg.Wait()
+	if n := atomic.LoadInt64(requests); n != 1 {
+		t.Errorf("sent %d requests, want 1", n)
+	}
+}
+
+func TestLoaderContext(t *testing.T) {
+	c, _ := newTestClient(t)
+	l := c.NewLoader(time.Hour)
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := l.Get(ctx, "1"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("got %v, want the error of ctx", err)
+	}
+}
@@ -0,0 +1,63 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// Decoder decodes a request body of some media type into an article, for WithDecoder.
+type Decoder func(body io.Reader, a *Article) error
+
+// FormDecoder decodes application/x-www-form-urlencoded bodies, whose keys are the json names of
+// the fields of Article, such as title or description.
+func FormDecoder(body io.Reader, a *Article) error {
+	b, err := io.ReadAll(body)
+	if err != nil {
+		return err
+	}
+	form, err := url.ParseQuery(string(b))
+	if err != nil {
+		return err
+	}
+	a.ID = form.Get("id")
+	a.Title = form.Get("title")
+	a.Desc = form.Get("description")
+	a.Content = form.Get("content")
+	a.Author = form.Get("author")
+	a.Status = form.Get("status")
+	if p := form.Get("position"); p != "" {
+		if a.Position, err = strconv.Atoi(p); err != nil {
+			return fmt.Errorf("position must be an integer, got %q", p)
+		}
+	}
+	return nil
+}
+
+// decodeArticle decodes the body of r into a, as json or with the decoder given to WithDecoder for its media type.
+// Otherwise, it answers 415, or 400 if the body could not be decoded, and returns false.
+func (s *ArticleService) decodeArticle(w http.ResponseWriter, r *http.Request, a *Article) bool {
+	defer r.Body.Close()
+	mt, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err == nil && mt == "application/json" {
+		if err := json.NewDecoder(r.Body).Decode(a); err != nil {
+			writeError(w, http.StatusBadRequest, decodeErrorMessage(err))
+			return false
+		}
+		return true
+	}
+	decode, ok := s.decoders[mt]
+	if err != nil || !ok {
+		writeError(w, http.StatusUnsupportedMediaType, "unsupported content type")
+		return false
+	}
+	if err := decode(r.Body, a); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("could not decode %s: %v", mt, err))
+		return false
+	}
+	return true
+}
@@ -0,0 +1,69 @@
+package service
+
+import (
+	"bufio"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// yamlDecoder decodes flat "key: value" documents, enough to test WithDecoder.
+func yamlDecoder(body io.Reader, a *Article) error {
+	sc := bufio.NewScanner(body)
+	for sc.Scan() {
+		k, v, _ := strings.Cut(sc.Text(), ":")
+		switch strings.TrimSpace(k) {
+		case "title":
+			a.Title = strings.TrimSpace(v)
+		case "content":
+			a.Content = strings.TrimSpace(v)
+		}
+	}
+	return sc.Err()
+}
+
+func TestFormDecoder(t *testing.T) {
+	var a Article
+	if err := FormDecoder(strings.NewReader("title=a+title&description=d&content=c&author=ann&status=draft&position=3"), &a); err != nil {
+		t.Fatal(err)
+	}
+	if want := (Article{Title: "a title", Desc: "d", Content: "c", Author: "ann", Status: "draft", Position: 3}); a != want {
+		t.Errorf("got %+v, want %+v", a, want)
+	}
+	if err := FormDecoder(strings.NewReader("position=first"), &a); err == nil {
+		t.Errorf("non-integer position decoded")
+	}
+}
+
+func TestDecoders(t *testing.T) {
+	s := newTestService(t,
+		WithDecoder("application/x-www-form-urlencoded", FormDecoder),
+		WithDecoder("application/yaml", yamlDecoder),
+	)
+	h := s.RESTful()
+	for _, tc := range []struct{ contentType, body, title string }{
+		{"application/json", `{"title":"json"}`, "json"},
+		{"application/x-www-form-urlencoded", "title=form&content=c", "form"},
+		{"application/yaml; charset=utf-8", "title: yaml\ncontent: c\n", "yaml"},
+	} {
+		rec := serve(h, "POST", "/article", tc.body, "Content-Type", tc.contentType)
+		var created Article
+		decode(t, rec, &created)
+		if rec.Code != http.StatusCreated || created.Title != tc.title {
+			t.Errorf("%s got %d %s", tc.contentType, rec.Code, rec.Body)
+		}
+	}
+	for contentType, want := range map[string]int{
+		"text/plain":                        http.StatusUnsupportedMediaType,
+		"":                                  http.StatusUnsupportedMediaType,
+		"application/x-www-form-urlencoded": http.StatusBadRequest,
+	} {
+		if rec := serve(h, "POST", "/article", "position=x", "Content-Type", contentType); rec.Code != want {
+			t.Errorf("%q got %d, want %d", contentType, rec.Code, want)
+		}
+	}
+	if rec := serve(newTestService(t).RESTful(), "POST", "/article", "title=form", "Content-Type", "application/x-www-form-urlencoded"); rec.Code != http.StatusUnsupportedMediaType {
+		t.Errorf("form without its decoder got %d, want 415", rec.Code)
+	}
+}
@@ -1,11 +1,11 @@
 module example.com/service
 
-go 1.15
+go 1.21
 
 require (
 	github.com/go-sql-driver/mysql v1.5.0 // indirect
 	github.com/gorilla/mux v1.8.0
-	github.com/mattn/go-sqlite3 v1.14.5 // indirect
+	github.com/mattn/go-sqlite3 v1.14.5
 	github.com/onsi/ginkgo v1.14.2 // indirect
 	github.com/onsi/gomega v1.10.3 // indirect
 	github.com/proullon/ramsql v0.0.0-20181213202341-817cee58a244
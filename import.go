@@ -0,0 +1,134 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"syscall"
+	"time"
+)
+
+const (
+	// importTimeout bounds fetching content to import, redirects included.
+	importTimeout = 10 * time.Second
+	// importMaxBytes is the largest body accepted when importing content.
+	importMaxBytes = 4 << 20
+)
+
+// ErrImportBlocked is returned, wrapped, when content is to be imported from a URL that is not
+// http or https, or from an internal address whose host is not allowlisted.
+var ErrImportBlocked = errors.New("import url not allowed")
+
+// ErrImportFailed is returned, wrapped, when content to import could not be fetched.
+var ErrImportFailed = errors.New("could not fetch content")
+
+// ImportContentFromURL replaces the content of article id by the body fetched from rawURL, and bumps its updated_at.
+// Only http and https are fetched, and loopback, private and link-local addresses are refused unless
+// their host is given to WithImportAllowlist. The address is checked when connecting, so neither DNS
+// nor redirects get around it.
+func (s *ArticleService) ImportContentFromURL(ctx context.Context, id, rawURL string) error {
+	stat := `UPDATE articles SET content = ?, updated_at = ? WHERE id = ?;`
+	if s.DB == nil {
+		panic("no existing database")
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("%w: %s", ErrImportBlocked, rawURL)
+	}
+	// Don't fetch anything on behalf of an article that doesn't exist.
+	if _, err := s.Get(ctx, id); err != nil {
+		return err
+	}
+	content, err := s.fetchContent(ctx, u.String())
+	if err != nil {
+		return err
+	}
+	if err := s.validate(Article{Content: content}); err != nil {
+		return err
+	}
+	res, err := s.db().ExecContext(ctx, stat, content, time.Now().UTC(), id)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return fmt.Errorf("%w: id %s", ErrNotFound, id)
+	}
+	return nil
+}
+
+func (s *ArticleService) fetchContent(ctx context.Context, rawURL string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrImportBlocked, err)
+	}
+	resp, err := s.importClient().Do(req)
+	if errors.Is(err, ErrImportBlocked) {
+		return "", err
+	}
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrImportFailed, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return "", fmt.Errorf("%w: %s answered %s", ErrImportFailed, rawURL, resp.Status)
+	}
+	b, err := io.ReadAll(io.LimitReader(resp.Body, importMaxBytes+1))
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrImportFailed, err)
+	}
+	if len(b) > importMaxBytes {
+		return "", fmt.Errorf("%w: body is larger than %d bytes", ErrImportFailed, importMaxBytes)
+	}
+	return string(b), nil
+}
+
+// importClient returns a client that refuses to connect to internal addresses of hosts not allowlisted.
+// It ignores proxy settings from the environment, which would hide the address actually reached.
+func (s *ArticleService) importClient() *http.Client {
+	open := &net.Dialer{Timeout: importTimeout}
+	guarded := &net.Dialer{Timeout: importTimeout, Control: refuseInternal}
+	return &http.Client{
+		Timeout: importTimeout,
+		Transport: &http.Transport{
+			DisableKeepAlives: true,
+			DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+				host, _, err := net.SplitHostPort(addr)
+				if err == nil && s.importAllowlist[host] {
+					return open.DialContext(ctx, network, addr)
+				}
+				return guarded.DialContext(ctx, network, addr)
+			},
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+				return fmt.Errorf("%w: redirected to %s", ErrImportBlocked, req.URL)
+			}
+			if len(via) >= 5 {
+				return fmt.Errorf("%w: too many redirects", ErrImportFailed)
+			}
+			return nil
+		},
+	}
+}
+
+// refuseInternal is a net.Dialer Control refusing to connect to loopback, private, link-local or unspecified addresses.
+func refuseInternal(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrImportBlocked, err)
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() {
+		return fmt.Errorf("%w: %s is an internal address", ErrImportBlocked, host)
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// contentServer serves body at /, 404 elsewhere, and redirects /redirect to target.
+func contentServer(t *testing.T, body, target string) *httptest.Server {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/":
+			w.Write([]byte(body))
+		case "/redirect":
+			http.Redirect(w, r, target, http.StatusFound)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestImportContentFromURL(t *testing.T) {
+	srv := contentServer(t, "imported", "file:///etc/passwd")
+	host := strings.Split(strings.TrimPrefix(srv.URL, "http://"), ":")[0]
+	ctx := context.Background()
+
+	s := newTestService(t, WithImportAllowlist(host))
+	a := mustCreate(t, s, Article{Title: "a", Content: "old"})
+	if err := s.ImportContentFromURL(ctx, a.ID, srv.URL); err != nil {
+		t.Fatal(err)
+	}
+	got, _ := s.Get(ctx, a.ID)
+	if got.Content != "imported" || !got.UpdatedAt.After(a.UpdatedAt) {
+		t.Errorf("got %+v", got)
+	}
+	for rawURL, want := range map[string]error{
+		srv.URL + "/missing":  ErrImportFailed,
+		srv.URL + "/redirect": ErrImportBlocked,
+		"ftp://" + host:       ErrImportBlocked,
+		"not a url":           ErrImportBlocked,
+	} {
+		if err := s.ImportContentFromURL(ctx, a.ID, rawURL); !errors.Is(err, want) {
+			t.Errorf("%s got %v, want %v", rawURL, err, want)
+		}
+	}
+	if err := s.ImportContentFromURL(ctx, "404", srv.URL); !errors.Is(err, ErrNotFound) {
+		t.Errorf("got %v, want ErrNotFound", err)
+	}
+
+	blocked := newTestService(t)
+	b := mustCreate(t, blocked, Article{Title: "b", Content: "kept"})
+	if err := blocked.ImportContentFromURL(ctx, b.ID, srv.URL); !errors.Is(err, ErrImportBlocked) {
+		t.Errorf("loopback without allowlist got %v, want ErrImportBlocked", err)
+	}
+	if got, _ := blocked.Get(ctx, b.ID); got.Content != "kept" {
+		t.Errorf("blocked import changed the content to %q", got.Content)
+	}
+}
+
+func TestImportRoute(t *testing.T) {
+	srv := contentServer(t, "imported", "")
+	u, _ := url.Parse(srv.URL)
+	s := newTestService(t, WithImportAllowlist(u.Hostname()), WithFieldLimits(FieldLimits{Content: 4}))
+	h := s.RESTful()
+	mustCreate(t, s, Article{Title: "a"})
+	for _, tc := range []struct {
+		id, body string
+		want     int
+	}{
+		{"1", `{"url":"` + srv.URL + `"}`, http.StatusBadRequest},
+		{"1", `{"url":"` + srv.URL + `/missing"}`, http.StatusBadGateway},
+		{"1", `{"url":"http://169.254.169.254/latest"}`, http.StatusBadRequest},
+		{"404", `{"url":"` + srv.URL + `"}`, http.StatusNotFound},
+		{"1", `{"url":`, http.StatusBadRequest},
+	} {
+		if rec := serve(h, "POST", "/article/"+tc.id+"/import", tc.body); rec.Code != tc.want {
+			t.Errorf("%s %s got %d %s, want %d", tc.id, tc.body, rec.Code, rec.Body, tc.want)
+		}
+	}
+	if e := errorOf(t, serve(h, "POST", "/article/1/import", `{"url":"`+srv.URL+`"}`)); e.Fields["content"] == "" {
+		t.Errorf("content over its limit got %+v", e)
+	}
+
+	ok := newTestService(t, WithImportAllowlist(u.Hostname()))
+	mustCreate(t, ok, Article{Title: "a"})
+	if rec := serve(ok.RESTful(), "POST", "/article/1/import", `{"url":"`+srv.URL+`"}`); rec.Code != http.StatusOK {
+		t.Errorf("got %d %s", rec.Code, rec.Body)
+	}
+}
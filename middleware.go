@@ -0,0 +1,225 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// requestTimeout returns the timeout for a request of the given method, zero meaning none.
+func (s *ArticleService) requestTimeout(method string) time.Duration {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		if s.readTimeout > 0 {
+			return s.readTimeout
+		}
+	default:
+		if s.writeTimeout > 0 {
+			return s.writeTimeout
+		}
+	}
+	return s.timeout
+}
+
+// serverHeaders stamps every response with the Version of the instance serving it and the Date,
+// telling apart instances behind a load balancer.
+func serverHeaders(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Service-Version", Version)
+		w.Header().Set("Date", time.Now().UTC().Format(http.TimeFormat))
+		next.ServeHTTP(w, r)
+	})
+}
+
+// HSTS configures WithHSTS.
+type HSTS struct {
+	// MaxAge is how long browsers should only reach the service over https.
+	MaxAge time.Duration
+	// IncludeSubdomains extends the policy to the subdomains of the host.
+	IncludeSubdomains bool
+	// Redirect sends requests a TLS terminator forwarded as plain http, per X-Forwarded-Proto, to https.
+	Redirect bool
+}
+
+// enforceHTTPS sets the Strict-Transport-Security header and redirects plain http requests as
+// configured by WithHSTS, and does nothing without it.
+func (s *ArticleService) enforceHTTPS(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.hsts == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if s.hsts.Redirect && r.TLS == nil && strings.EqualFold(forwarded(r, "X-Forwarded-Proto"), "http") {
+			// 308 rather than 301, so that clients replay the method and body.
+			http.Redirect(w, r, "https://"+r.Host+r.RequestURI, http.StatusPermanentRedirect)
+			return
+		}
+		v := "max-age=" + strconv.FormatInt(int64(s.hsts.MaxAge/time.Second), 10)
+		if s.hsts.IncludeSubdomains {
+			v += "; includeSubDomains"
+		}
+		w.Header().Set("Strict-Transport-Security", v)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// noStoreWrites keeps caches from storing the responses to requests that could change articles.
+func noStoreWrites(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+		default:
+			w.Header().Set("Cache-Control", "no-store")
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// rejectWrites answers 405 to requests that could change articles when the service is read-only.
+func (s *ArticleService) rejectWrites(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+		default:
+			if s.readOnly {
+				w.Header().Set("Allow", "GET, HEAD, OPTIONS")
+				writeError(w, http.StatusMethodNotAllowed, "service is read-only")
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// withTimeout bounds the request context by the read or write timeout matching its method.
+// A gateway may ask for a shorter deadline with an X-Request-Timeout header, in milliseconds;
+// it never extends the configured timeout, and applies as is when none is configured.
+func (s *ArticleService) withTimeout(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		d := s.requestTimeout(r.Method)
+		if ms, err := strconv.ParseInt(r.Header.Get("X-Request-Timeout"), 10, 64); err == nil && ms > 0 {
+			if hd := time.Duration(ms) * time.Millisecond; d == 0 || hd < d {
+				d = hd
+			}
+		}
+		if d > 0 {
+			ctx, cancel := context.WithTimeout(r.Context(), d)
+			defer cancel()
+			r = r.WithContext(ctx)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// enforceDeadline answers 503 to requests not handled within the duration set by WithRequestTimeout, whether
+// or not the handler heeds its context, which is canceled as well. Responses are buffered until the handler
+// returns, so streamed ones are sent at once.
+func (s *ArticleService) enforceDeadline(next http.Handler) http.Handler {
+	if s.deadline <= 0 {
+		return next
+	}
+	msg, _ := json.Marshal(struct {
+		Error apiError `json:"error"`
+	}{apiError{Code: errorCode(http.StatusServiceUnavailable), Message: "request timed out"}})
+	th := http.TimeoutHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Headers set here overwrite those set below once the handler returns in time, so unset the
+		// Content-Type meant for the timeout reply; a nil value also keeps it from being sniffed.
+		w.Header()["Content-Type"] = nil
+		next.ServeHTTP(w, r)
+	}), s.deadline, string(msg))
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		th.ServeHTTP(w, r)
+	})
+}
+
+// accessLog holds what a handler reports about the article operation it served, for logAccess.
+// A handler cut short by enforceDeadline may still report while logAccess reads it, hence mu.
+type accessLog struct {
+	mu sync.Mutex
+	op string
+	id string
+}
+
+type accessLogKey struct{}
+
+// logArticle records that r served operation op on article id.
+func logArticle(r *http.Request, op, id string) {
+	if l, ok := r.Context().Value(accessLogKey{}).(*accessLog); ok {
+		l.mu.Lock()
+		l.op, l.id = op, id
+		l.mu.Unlock()
+	}
+}
+
+// statusRecorder remembers the status written through it.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (sr *statusRecorder) WriteHeader(status int) {
+	if sr.status == 0 {
+		sr.status = status
+	}
+	sr.ResponseWriter.WriteHeader(status)
+}
+
+func (sr *statusRecorder) Write(b []byte) (int, error) {
+	if sr.status == 0 {
+		sr.status = http.StatusOK
+	}
+	return sr.ResponseWriter.Write(b)
+}
+
+func (sr *statusRecorder) Flush() {
+	if f, ok := sr.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// logAccess logs one line per article operation with its outcome and duration, and the role of the caller when API keys are in use.
+// With WithLogSampling, only a sample of successful operations is logged, and every failed one.
+func (s *ArticleService) logAccess(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		l := &accessLog{}
+		sr := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(sr, r.WithContext(context.WithValue(r.Context(), accessLogKey{}, l)))
+		l.mu.Lock()
+		op, id := l.op, l.id
+		l.mu.Unlock()
+		if op == "" {
+			return
+		}
+		if sr.status == 0 {
+			sr.status = http.StatusOK
+		}
+		if s.logRate != nil && sr.status < 400 && rand.Float64() >= *s.logRate {
+			return
+		}
+		attrs := []slog.Attr{
+			slog.String("op", op),
+			slog.String("id", id),
+			slog.Int("status", sr.status),
+			slog.Duration("duration", time.Since(start)),
+		}
+		if len(s.apiKeys) > 0 {
+			attrs = append(attrs, slog.String("caller", string(roleFrom(r.Context()))))
+		}
+		s.logger().LogAttrs(r.Context(), slog.LevelInfo, "article access", attrs...)
+	})
+}
+
+func (s *ArticleService) logger() *slog.Logger {
+	if s.log != nil {
+		return s.log
+	}
+	return slog.Default()
+}
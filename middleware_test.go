@@ -0,0 +1,308 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// syncBuffer is a bytes.Buffer safe for handlers logging from several goroutines.
+type syncBuffer struct {
+	mu sync.Mutex
+	b  bytes.Buffer
+}
+
+func (sb *syncBuffer) Write(p []byte) (int, error) {
+	sb.mu.Lock()
+	defer sb.mu.Unlock()
+	return sb.b.Write(p)
+}
+
+// records decodes the json log records written so far.
+func (sb *syncBuffer) records(t *testing.T) []map[string]interface{} {
+	t.Helper()
+	sb.mu.Lock()
+	defer sb.mu.Unlock()
+	var records []map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(sb.b.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		var rec map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &rec); err != nil {
+			t.Fatalf("could not decode log record %q: %v", line, err)
+		}
+		records = append(records, rec)
+	}
+	return records
+}
+
+// recordsOf returns the log records with message msg.
+func (sb *syncBuffer) recordsOf(t *testing.T, msg string) []map[string]interface{} {
+	var matching []map[string]interface{}
+	for _, rec := range sb.records(t) {
+		if rec["msg"] == msg {
+			matching = append(matching, rec)
+		}
+	}
+	return matching
+}
+
+// withTestLogger returns an option logging json records into the returned buffer.
+func withTestLogger() (Option, *syncBuffer) {
+	sb := &syncBuffer{}
+	return WithLogger(slog.New(slog.NewJSONHandler(sb, nil))), sb
+}
+
+func TestServerHeaders(t *testing.T) {
+	defer func(v string) { Version = v }(Version)
+	Version = "1.2.3"
+	h := newTestService(t).RESTful()
+	for _, target := range []string{"/list", "/article/404", "/no/such/route"} {
+		rec := serve(h, "GET", target, "")
+		if rec.Header().Get("X-Service-Version") != "1.2.3" {
+			t.Errorf("%s got X-Service-Version %q", target, rec.Header().Get("X-Service-Version"))
+		}
+		if _, err := http.ParseTime(rec.Header().Get("Date")); err != nil {
+			t.Errorf("%s got Date %q", target, rec.Header().Get("Date"))
+		}
+	}
+}
+
+func TestNoStoreWrites(t *testing.T) {
+	s := newTestService(t, WithListCacheControl(time.Minute))
+	h := s.RESTful()
+	mustCreate(t, s, Article{Title: "a"})
+	for _, tc := range []struct{ method, target, want string }{
+		{"GET", "/list", "max-age=60"},
+		{"GET", "/search?q=a", ""},
+		{"GET", "/article/1", "no-store"},
+		{"POST", "/article", "no-store"},
+		{"DELETE", "/article/404", "no-store"},
+		{"POST", "/article/1/touch", "no-store"},
+	} {
+		if got := serve(h, tc.method, tc.target, `{}`).Header().Get("Cache-Control"); got != tc.want {
+			t.Errorf("%s %s got Cache-Control %q, want %q", tc.method, tc.target, got, tc.want)
+		}
+	}
+	if got := serve(newTestService(t).RESTful(), "GET", "/list", "").Header().Get("Cache-Control"); got != "" {
+		t.Errorf("got Cache-Control %q by default", got)
+	}
+}
+
+func TestListCacheVariesByKey(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		opts []Option
+		want string
+	}{
+		{"no keys", nil, ""},
+		{"api keys", []Option{WithAPIKey("reader-key", RoleReader)}, "X-API-Key"},
+		{"field policy", []Option{WithFieldPolicy(FieldPolicy{RoleAnonymous: {"content"}})}, "X-API-Key"},
+	} {
+		s := newTestService(t, append(tc.opts, WithListCacheControl(time.Minute))...)
+		rec := serve(s.RESTful(), "GET", "/list", "")
+		if got := rec.Header().Get("Vary"); rec.Code != http.StatusOK || got != tc.want {
+			t.Errorf("%s: got %d, Vary %q, want %q", tc.name, rec.Code, got, tc.want)
+		}
+	}
+}
+
+func TestHSTS(t *testing.T) {
+	if rec := serve(newTestService(t).RESTful(), "GET", "/list", ""); rec.Header().Get("Strict-Transport-Security") != "" {
+		t.Errorf("HSTS sent while disabled")
+	}
+	h := newTestService(t, WithHSTS(HSTS{MaxAge: 24 * time.Hour, IncludeSubdomains: true, Redirect: true})).RESTful()
+	rec := serve(h, "GET", "/list", "", "X-Forwarded-Proto", "https")
+	if got := rec.Header().Get("Strict-Transport-Security"); got != "max-age=86400; includeSubDomains" {
+		t.Errorf("got Strict-Transport-Security %q", got)
+	}
+	rec = serve(h, "POST", "/article?pretty=true", `{}`, "X-Forwarded-Proto", "http")
+	if rec.Code != http.StatusPermanentRedirect || rec.Header().Get("Location") != "https://example.com/article?pretty=true" {
+		t.Errorf("plain http got %d to %q", rec.Code, rec.Header().Get("Location"))
+	}
+}
+
+func TestReadOnly(t *testing.T) {
+	s := newTestService(t, WithReadOnly(), WithAPIKey("admin-key", RoleAdmin))
+	h := s.RESTful()
+	mustCreate(t, s, Article{Title: "a"})
+	for _, tc := range []struct{ method, target string }{
+		{"POST", "/article"}, {"PUT", "/article/7"}, {"DELETE", "/article/1"}, {"PUT", "/articles"},
+		{"POST", "/article/1/touch"}, {"PUT", "/articles/order"},
+	} {
+		rec := serve(h, tc.method, tc.target, `{}`, "X-API-Key", "admin-key")
+		if rec.Code != http.StatusMethodNotAllowed || rec.Header().Get("Allow") != "GET, HEAD, OPTIONS" {
+			t.Errorf("%s %s got %d, Allow %q", tc.method, tc.target, rec.Code, rec.Header().Get("Allow"))
+		}
+	}
+	if rec := serve(h, "GET", "/article/1", ""); rec.Code != http.StatusOK {
+		t.Errorf("GET got %d", rec.Code)
+	}
+	if n, _ := s.Count(context.Background()); n != 1 {
+		t.Errorf("got %d articles, want 1", n)
+	}
+}
+
+func TestRequestTimeoutHeader(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		opts   []Option
+		header string
+	}{
+		{"shorter than configured", []Option{WithTimeout(time.Minute)}, "20"},
+		{"none configured", nil, "20"},
+		{"never extends", []Option{WithReadTimeout(20 * time.Millisecond)}, "60000"},
+		{"ignored when invalid", []Option{WithReadTimeout(20 * time.Millisecond)}, "soon"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			s := newTestService(t, tc.opts...)
+			holdConn(t, s)
+			start := time.Now()
+			rec := serve(s.RESTful(), "GET", "/article/1", "", "X-Request-Timeout", tc.header)
+			if rec.Code != http.StatusServiceUnavailable || time.Since(start) > 5*time.Second {
+				t.Errorf("got %d after %s, want 503 within the timeout", rec.Code, time.Since(start))
+			}
+		})
+	}
+}
+
+func TestReadAndWriteTimeouts(t *testing.T) {
+	s := newTestService(t, WithReadTimeout(20*time.Millisecond), WithWriteTimeout(time.Hour))
+	if got := s.requestTimeout(http.MethodGet); got != 20*time.Millisecond {
+		t.Errorf("GET timeout %s", got)
+	}
+	if got := s.requestTimeout(http.MethodPost); got != time.Hour {
+		t.Errorf("POST timeout %s", got)
+	}
+	if got := newTestService(t, WithTimeout(time.Second)).requestTimeout(http.MethodDelete); got != time.Second {
+		t.Errorf("DELETE timeout %s, want the one of WithTimeout", got)
+	}
+}
+
+func TestReadAndWriteTimeoutsPerRoute(t *testing.T) {
+	get := []string{"GET", "/article/1", ""}
+	post := []string{"POST", "/article", `{"title":"b"}`}
+	for _, tc := range []struct {
+		name       string
+		opts       []Option
+		slow, fast []string
+	}{
+		{"read", []Option{WithReadTimeout(20 * time.Millisecond), WithWriteTimeout(time.Minute)}, get, post},
+		{"write", []Option{WithWriteTimeout(20 * time.Millisecond), WithReadTimeout(time.Minute)}, post, get},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			s := newTestService(t, tc.opts...)
+			h := s.RESTful()
+			mustCreate(t, s, Article{Title: "a"})
+			release := holdConn(t, s)
+			start := time.Now()
+			rec := serve(h, tc.slow[0], tc.slow[1], tc.slow[2])
+			if d := time.Since(start); rec.Code != http.StatusServiceUnavailable || d < 20*time.Millisecond || d > 5*time.Second {
+				t.Errorf("%s waiting for the connection got %d after %s, want 503 once its timeout is over", tc.slow[0], rec.Code, d)
+			}
+			// Waiting past the other timeout, a request of the other kind is still served.
+			time.AfterFunc(100*time.Millisecond, release)
+			start = time.Now()
+			if rec := serve(h, tc.fast[0], tc.fast[1], tc.fast[2]); rec.Code >= 300 || time.Since(start) < 100*time.Millisecond {
+				t.Errorf("%s waiting for the connection got %d after %s, want it served once released", tc.fast[0], rec.Code, time.Since(start))
+			}
+		})
+	}
+}
+
+func TestEnforceDeadline(t *testing.T) {
+	s := newTestService(t, WithRequestTimeout(30*time.Millisecond))
+	h := s.RESTful()
+	mustCreate(t, s, Article{Title: "a"})
+	if rec := serve(h, "GET", "/article/1", ""); rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "application/json" {
+		t.Fatalf("fast request got %d, Content-Type %q", rec.Code, rec.Header().Get("Content-Type"))
+	}
+	if rec := serve(h, "GET", "/article/1/content", ""); rec.Header().Get("Content-Type") != "text/plain; charset=utf-8" {
+		t.Errorf("fast request got Content-Type %q", rec.Header().Get("Content-Type"))
+	}
+
+	// A handler waiting for the only connection can reply only when the deadline is long gone.
+	holdConn(t, s)
+	start := time.Now()
+	rec := serve(h, "GET", "/article/1", "")
+	if rec.Code != http.StatusServiceUnavailable || rec.Header().Get("Content-Type") != "application/json" {
+		t.Fatalf("slow request got %d, Content-Type %q", rec.Code, rec.Header().Get("Content-Type"))
+	}
+	if e := errorOf(t, rec); e.Code != "service_unavailable" || e.Message != "request timed out" {
+		t.Errorf("got %+v", e)
+	}
+	if d := time.Since(start); d > 5*time.Second {
+		t.Errorf("answered after %s", d)
+	}
+}
+
+func TestLogAccess(t *testing.T) {
+	logTo, logs := withTestLogger()
+	s := newTestService(t, logTo, WithAPIKey("reader-key", RoleReader))
+	h := s.RESTful()
+	serve(h, "POST", "/article", `{"title":"a"}`)
+	serve(h, "GET", "/article/404", "", "X-API-Key", "reader-key")
+	serve(h, "GET", "/list", "")
+
+	records := logs.recordsOf(t, "article access")
+	if len(records) != 2 {
+		t.Fatalf("got %d access records, want one per article operation: %v", len(records), records)
+	}
+	for i, want := range []map[string]interface{}{
+		{"op": "create", "id": "1", "status": float64(201), "caller": "anonymous"},
+		{"op": "get", "id": "404", "status": float64(404), "caller": "reader"},
+	} {
+		for k, v := range want {
+			if records[i][k] != v {
+				t.Errorf("record %d has %s %v, want %v", i, k, records[i][k], v)
+			}
+		}
+		if _, ok := records[i]["duration"]; !ok {
+			t.Errorf("record %d lacks a duration", i)
+		}
+	}
+}
+
+func TestLogAccessTimedOut(t *testing.T) {
+	logTo, logs := withTestLogger()
+	s := newTestService(t, logTo, WithRequestTimeout(30*time.Millisecond))
+	holdConn(t, s)
+	if rec := serve(s.RESTful(), "GET", "/article/1", ""); rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("got %d, want 503", rec.Code)
+	}
+	records := logs.recordsOf(t, "article access")
+	if len(records) != 1 || records[0]["op"] != "get" || records[0]["status"] != float64(503) {
+		t.Errorf("got %v, want the get logged with status 503", records)
+	}
+}
+
+func TestLogSampling(t *testing.T) {
+	logTo, logs := withTestLogger()
+	s := newTestService(t, logTo, WithLogSampling(0))
+	h := s.RESTful()
+	mustCreate(t, s, Article{Title: "a"})
+	for i := 0; i < 10; i++ {
+		serve(h, "GET", "/article/1", "")
+	}
+	serve(h, "GET", "/article/404", "")
+	records := logs.recordsOf(t, "article access")
+	if len(records) != 1 || records[0]["status"] != float64(404) {
+		t.Errorf("got %v, want only the failed operation", records)
+	}
+
+	logTo, logs = withTestLogger()
+	h = newTestService(t, logTo, WithLogSampling(1)).RESTful()
+	for i := 0; i < 10; i++ {
+		serve(h, "GET", "/article/404", "")
+	}
+	if n := len(logs.recordsOf(t, "article access")); n != 10 {
+		t.Errorf("got %d records, want all 10", n)
+	}
+}
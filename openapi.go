@@ -0,0 +1,707 @@
+package service
+
+// openAPISpec describes the RESTful API of article service, served at /openapi.json.
+// Keep it in step with registerRoutes when routes or payloads change.
+const openAPISpec = `{
+  "openapi": "3.0.3",
+  "info": {
+    "title": "Article service",
+    "version": "1.0.0"
+  },
+  "paths": {
+    "/list": {
+      "get": {
+        "summary": "List articles, all of them unless filtered",
+        "parameters": [
+          {"name": "author", "in": "query", "schema": {"type": "string"}},
+          {"name": "status", "in": "query", "schema": {"type": "string"}},
+          {"name": "q", "in": "query", "description": "Text contained in the title, description or content, among the fields the caller may see", "schema": {"type": "string"}},
+          {"name": "updated_after", "in": "query", "description": "Earliest updated_at, inclusive", "schema": {"type": "string", "format": "date-time"}},
+          {"name": "updated_before", "in": "query", "description": "Latest updated_at, exclusive", "schema": {"type": "string", "format": "date-time"}},
+          {"name": "sort", "in": "query", "description": "Column to sort by, id, position or updated_at, prefixed by - for descending order; id unless the service is set to another default", "schema": {"type": "string"}},
+          {"name": "limit", "in": "query", "description": "Number of articles per page, within the maximum the service allows; all of them or the default page size if absent", "schema": {"type": "integer", "minimum": 1}},
+          {"name": "offset", "in": "query", "description": "Number of articles to skip, with limit", "schema": {"type": "integer", "minimum": 0}},
+          {"name": "If-Modified-Since", "in": "header", "description": "Answer 304 if no article was created, updated, archived or deleted since", "schema": {"type": "string"}}
+        ],
+        "responses": {
+          "200": {
+            "description": "Matching articles, or a page of them",
+            "headers": {
+              "Link": {"description": "Links to the first, prev and next pages, when paged", "schema": {"type": "string"}},
+              "Last-Modified": {"description": "When articles last changed, rounded up to the second; absent until that second is over", "schema": {"type": "string"}},
+              "Cache-Control": {"description": "How long caches may serve the list, when the service allows it", "schema": {"type": "string"}},
+              "Vary": {"description": "X-API-Key along with Cache-Control, when what the list holds depends on the caller", "schema": {"type": "string"}}
+            },
+            "content": {
+              "application/json": {
+                "schema": {"type": "array", "items": {"$ref": "#/components/schemas/Article"}}
+              }
+            }
+          },
+          "304": {"description": "No article changed since If-Modified-Since"},
+          "400": {"$ref": "#/components/responses/Error"},
+          "500": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/list/ids": {
+      "get": {
+        "summary": "List only the ids of articles, filtered as by /list",
+        "parameters": [
+          {"name": "author", "in": "query", "schema": {"type": "string"}},
+          {"name": "status", "in": "query", "schema": {"type": "string"}},
+          {"name": "q", "in": "query", "description": "Text contained in the title, description or content, among the fields the caller may see", "schema": {"type": "string"}},
+          {"name": "updated_after", "in": "query", "description": "Earliest updated_at, inclusive", "schema": {"type": "string", "format": "date-time"}},
+          {"name": "updated_before", "in": "query", "description": "Latest updated_at, exclusive", "schema": {"type": "string", "format": "date-time"}},
+          {"name": "sort", "in": "query", "description": "Column to sort by, id, position or updated_at, prefixed by - for descending order; id unless the service is set to another default", "schema": {"type": "string"}},
+          {"name": "limit", "in": "query", "schema": {"type": "integer", "minimum": 1}},
+          {"name": "offset", "in": "query", "schema": {"type": "integer", "minimum": 0}}
+        ],
+        "responses": {
+          "200": {
+            "description": "Ids of the matching articles, in order",
+            "content": {
+              "application/json": {
+                "schema": {"type": "array", "items": {"type": "string"}}
+              }
+            }
+          },
+          "400": {"$ref": "#/components/responses/Error"},
+          "500": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/search": {
+      "get": {
+        "summary": "Search articles by title, description or content",
+        "parameters": [
+          {"name": "q", "in": "query", "description": "Text looked for in the title, description and content, among the fields the caller may see", "schema": {"type": "string"}},
+          {"name": "ci", "in": "query", "description": "Match regardless of case", "schema": {"type": "boolean"}},
+          {"name": "mode", "in": "query", "description": "How q is matched: as a substring, the default, as a phrase of whole words, or as words each found anywhere", "schema": {"type": "string", "enum": ["substring", "phrase", "words"]}},
+          {"name": "limit", "in": "query", "description": "Number of articles per page, within the maximum the service allows", "schema": {"type": "integer", "minimum": 1}},
+          {"name": "offset", "in": "query", "description": "Number of articles to skip, with limit", "schema": {"type": "integer", "minimum": 0}}
+        ],
+        "responses": {
+          "200": {
+            "description": "Matching articles, or a page of them",
+            "headers": {
+              "X-Total-Count": {"description": "Number of matching articles", "schema": {"type": "integer"}},
+              "Link": {"description": "Links to the first, prev and next pages, when paged", "schema": {"type": "string"}}
+            },
+            "content": {
+              "application/json": {
+                "schema": {"type": "array", "items": {"$ref": "#/components/schemas/Article"}}
+              }
+            }
+          },
+          "400": {"$ref": "#/components/responses/Error"},
+          "500": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/search/stream": {
+      "get": {
+        "summary": "Stream matching articles as they are found",
+        "parameters": [
+          {"name": "q", "in": "query", "description": "Text looked for in the title, description and content, among the fields the caller may see", "schema": {"type": "string"}},
+          {"name": "ci", "in": "query", "description": "Match regardless of case", "schema": {"type": "boolean"}},
+          {"name": "mode", "in": "query", "description": "How q is matched: as a substring, the default, as a phrase of whole words, or as words each found anywhere", "schema": {"type": "string", "enum": ["substring", "phrase", "words"]}}
+        ],
+        "responses": {
+          "200": {
+            "description": "One matching article per line",
+            "content": {
+              "application/x-ndjson": {
+                "schema": {"$ref": "#/components/schemas/Article"}
+              }
+            }
+          },
+          "400": {"$ref": "#/components/responses/Error"},
+          "500": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/schema/article": {
+      "get": {
+        "summary": "Get the JSON Schema, draft 2020-12, of articles",
+        "responses": {
+          "200": {
+            "description": "Schema of articles, with the field limits in force",
+            "content": {
+              "application/json": {
+                "schema": {"type": "object"}
+              }
+            }
+          }
+        }
+      }
+    },
+    "/random": {
+      "get": {
+        "summary": "Get an article picked at random",
+        "responses": {
+          "200": {
+            "description": "An article",
+            "content": {
+              "application/json": {
+                "schema": {"$ref": "#/components/schemas/Article"}
+              }
+            }
+          },
+          "404": {"$ref": "#/components/responses/Error"},
+          "500": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/count": {
+      "get": {
+        "summary": "Count articles",
+        "parameters": [
+          {"name": "approx", "in": "query", "description": "Estimate from backend statistics, cheap on large tables but only as accurate as the last ANALYZE", "schema": {"type": "boolean"}}
+        ],
+        "responses": {
+          "200": {
+            "description": "Number of articles",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "type": "object",
+                  "properties": {
+                    "count": {"type": "integer"},
+                    "exact": {"type": "boolean", "description": "False if the count is an estimate"}
+                  }
+                }
+              }
+            }
+          },
+          "500": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/trending": {
+      "get": {
+        "summary": "List the most viewed articles of late",
+        "parameters": [
+          {"name": "window", "in": "query", "description": "How far back views are counted, as a Go duration such as 24h, the default", "schema": {"type": "string"}},
+          {"name": "limit", "in": "query", "description": "Number of articles, 10 by default and at most 100", "schema": {"type": "integer", "minimum": 1, "maximum": 100}}
+        ],
+        "responses": {
+          "200": {
+            "description": "Articles viewed within the window, most viewed first",
+            "content": {
+              "application/json": {
+                "schema": {"type": "array", "items": {"$ref": "#/components/schemas/Article"}}
+              }
+            }
+          },
+          "400": {"$ref": "#/components/responses/Error"},
+          "500": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/stats/status": {
+      "get": {
+        "summary": "Count articles per status",
+        "responses": {
+          "200": {
+            "description": "Number of articles by status, draft and published always present",
+            "content": {
+              "application/json": {
+                "schema": {"type": "object", "additionalProperties": {"type": "integer"}}
+              }
+            }
+          },
+          "500": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/export/delta": {
+      "get": {
+        "summary": "Read the articles changed and deleted within a window, for incremental export",
+        "parameters": [
+          {"name": "from", "in": "query", "required": true, "description": "Start of the window, inclusive", "schema": {"type": "string", "format": "date-time"}},
+          {"name": "to", "in": "query", "required": true, "description": "End of the window, inclusive, not before from", "schema": {"type": "string", "format": "date-time"}}
+        ],
+        "responses": {
+          "200": {
+            "description": "Articles created or updated within the window, and tombstones of those deleted or archived, oldest first",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "type": "object",
+                  "properties": {
+                    "changed": {"type": "array", "items": {"$ref": "#/components/schemas/Article"}},
+                    "deleted": {
+                      "type": "array",
+                      "items": {
+                        "type": "object",
+                        "properties": {
+                          "id": {"type": "string"},
+                          "deleted_at": {"type": "string", "format": "date-time"}
+                        }
+                      }
+                    }
+                  }
+                }
+              }
+            }
+          },
+          "400": {"$ref": "#/components/responses/Error"},
+          "500": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/sync": {
+      "get": {
+        "summary": "Get the articles created, updated or deleted since a time, oldest change first",
+        "parameters": [
+          {"name": "since", "in": "query", "description": "X-Sync-Time of the previous sync, every article if absent", "schema": {"type": "string", "format": "date-time"}}
+        ],
+        "responses": {
+          "200": {
+            "description": "Articles created or updated since, and tombstones of those deleted or archived since",
+            "headers": {
+              "X-Sync-Time": {"description": "Server time to pass as since next time", "schema": {"type": "string", "format": "date-time"}}
+            },
+            "content": {
+              "application/json": {
+                "schema": {
+                  "type": "object",
+                  "properties": {
+                    "changed": {"type": "array", "items": {"$ref": "#/components/schemas/Article"}},
+                    "deleted": {
+                      "type": "array",
+                      "items": {
+                        "type": "object",
+                        "properties": {
+                          "id": {"type": "string"},
+                          "deleted_at": {"type": "string", "format": "date-time"}
+                        }
+                      }
+                    }
+                  }
+                }
+              }
+            }
+          },
+          "400": {"$ref": "#/components/responses/Error"},
+          "500": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/articles/order": {
+      "put": {
+        "summary": "Set the position of articles to their order in the given list, the others following in the order they were in",
+        "requestBody": {
+          "required": true,
+          "content": {
+            "application/json": {
+              "schema": {"type": "array", "items": {"type": "string"}}
+            }
+          }
+        },
+        "responses": {
+          "200": {"description": "Articles reordered"},
+          "400": {"$ref": "#/components/responses/Error"},
+          "404": {"$ref": "#/components/responses/Error"},
+          "500": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/articles": {
+      "get": {
+        "summary": "Get articles by id, in the order of the given ids",
+        "parameters": [
+          {"name": "ids", "in": "query", "required": true, "description": "Comma separated ids, at most 100", "schema": {"type": "string"}},
+          {"name": "strict", "in": "query", "description": "Answer one item per id, null for missing articles, instead of leaving them out", "schema": {"type": "boolean"}}
+        ],
+        "responses": {
+          "200": {
+            "description": "The articles found",
+            "content": {
+              "application/json": {
+                "schema": {"type": "array", "items": {"allOf": [{"$ref": "#/components/schemas/Article"}], "nullable": true}}
+              }
+            }
+          },
+          "400": {"$ref": "#/components/responses/Error"},
+          "500": {"$ref": "#/components/responses/Error"}
+        }
+      },
+      "put": {
+        "summary": "Replace all articles at once, for admins",
+        "requestBody": {
+          "required": true,
+          "content": {
+            "application/json": {
+              "schema": {"type": "array", "items": {"$ref": "#/components/schemas/Article"}}
+            }
+          }
+        },
+        "responses": {
+          "200": {"description": "Articles replaced"},
+          "400": {"$ref": "#/components/responses/Error"},
+          "401": {"$ref": "#/components/responses/Error"},
+          "403": {"$ref": "#/components/responses/Error"},
+          "409": {"$ref": "#/components/responses/Error"},
+          "500": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/authors": {
+      "get": {
+        "summary": "List the distinct authors of articles",
+        "responses": {
+          "200": {
+            "description": "Authors in alphabetical order",
+            "content": {
+              "application/json": {
+                "schema": {"type": "array", "items": {"type": "string"}}
+              }
+            }
+          },
+          "500": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/authors/{author}/articles": {
+      "parameters": [
+        {"name": "author", "in": "path", "required": true, "schema": {"type": "string"}}
+      ],
+      "get": {
+        "summary": "List the articles of an author",
+        "parameters": [
+          {"name": "limit", "in": "query", "description": "Number of articles per page, within the maximum the service allows; all of them or the default page size if absent", "schema": {"type": "integer", "minimum": 1}},
+          {"name": "offset", "in": "query", "description": "Number of articles to skip, with limit", "schema": {"type": "integer", "minimum": 0}}
+        ],
+        "responses": {
+          "200": {
+            "description": "Articles of the author in id order, or a page of them, empty if there are none",
+            "headers": {
+              "X-Total-Count": {"description": "Number of articles of the author", "schema": {"type": "integer"}},
+              "Link": {"description": "Links to the first, prev and next pages, when paged", "schema": {"type": "string"}}
+            },
+            "content": {
+              "application/json": {
+                "schema": {"type": "array", "items": {"$ref": "#/components/schemas/Article"}}
+              }
+            }
+          },
+          "400": {"$ref": "#/components/responses/Error"},
+          "500": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/article": {
+      "post": {
+        "summary": "Create an article",
+        "description": "Bodies of other media types are accepted when the service is configured with a decoder for them.",
+        "requestBody": {
+          "required": true,
+          "content": {
+            "application/json": {
+              "schema": {"$ref": "#/components/schemas/Article"}
+            }
+          }
+        },
+        "responses": {
+          "201": {
+            "description": "The created article",
+            "headers": {
+              "Location": {"description": "Path of the created article", "schema": {"type": "string"}}
+            },
+            "content": {
+              "application/json": {
+                "schema": {"$ref": "#/components/schemas/Article"}
+              }
+            }
+          },
+          "400": {"$ref": "#/components/responses/Error"},
+          "415": {"$ref": "#/components/responses/Error"},
+          "500": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/article/{id}": {
+      "parameters": [
+        {"name": "id", "in": "path", "required": true, "schema": {"type": "string"}}
+      ],
+      "get": {
+        "summary": "Get an article",
+        "responses": {
+          "200": {
+            "description": "The article",
+            "content": {
+              "application/json": {
+                "schema": {"$ref": "#/components/schemas/Article"}
+              }
+            }
+          },
+          "400": {"$ref": "#/components/responses/Error"},
+          "500": {"$ref": "#/components/responses/Error"}
+        }
+      },
+      "put": {
+        "summary": "Create an article with the given id, failing if it is taken",
+        "parameters": [
+          {"name": "If-None-Match", "in": "header", "required": true, "schema": {"type": "string", "enum": ["*"]}}
+        ],
+        "requestBody": {
+          "required": true,
+          "content": {
+            "application/json": {
+              "schema": {"$ref": "#/components/schemas/Article"}
+            }
+          }
+        },
+        "responses": {
+          "201": {
+            "description": "The created article",
+            "content": {
+              "application/json": {
+                "schema": {"$ref": "#/components/schemas/Article"}
+              }
+            }
+          },
+          "400": {"$ref": "#/components/responses/Error"},
+          "409": {"$ref": "#/components/responses/Error"},
+          "428": {"$ref": "#/components/responses/Error"},
+          "500": {"$ref": "#/components/responses/Error"}
+        }
+      },
+      "delete": {
+        "summary": "Delete an article",
+        "responses": {
+          "200": {"description": "Article deleted"},
+          "400": {"$ref": "#/components/responses/Error"},
+          "404": {"$ref": "#/components/responses/Error"},
+          "500": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/article/{id}/neighbors": {
+      "parameters": [
+        {"name": "id", "in": "path", "required": true, "schema": {"type": "string"}}
+      ],
+      "get": {
+        "summary": "Get the articles right before and after an article",
+        "responses": {
+          "200": {
+            "description": "The neighbors, null at either end",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "type": "object",
+                  "properties": {
+                    "prev": {"allOf": [{"$ref": "#/components/schemas/Article"}], "nullable": true},
+                    "next": {"allOf": [{"$ref": "#/components/schemas/Article"}], "nullable": true}
+                  }
+                }
+              }
+            }
+          },
+          "404": {"$ref": "#/components/responses/Error"},
+          "500": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/article/{id}/stats": {
+      "parameters": [
+        {"name": "id", "in": "path", "required": true, "schema": {"type": "string"}}
+      ],
+      "get": {
+        "summary": "Count the words and characters of the content of an article",
+        "responses": {
+          "200": {
+            "description": "Content statistics",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "type": "object",
+                  "properties": {
+                    "words": {"type": "integer"},
+                    "characters": {"type": "integer"}
+                  }
+                }
+              }
+            }
+          },
+          "403": {"$ref": "#/components/responses/Error"},
+          "404": {"$ref": "#/components/responses/Error"},
+          "500": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/article/{id}/content": {
+      "parameters": [
+        {"name": "id", "in": "path", "required": true, "schema": {"type": "string"}}
+      ],
+      "get": {
+        "summary": "Get the raw content of an article, whole or by byte range",
+        "parameters": [
+          {"name": "Range", "in": "header", "schema": {"type": "string", "example": "bytes=0-1023"}}
+        ],
+        "responses": {
+          "200": {
+            "description": "The whole content",
+            "content": {"text/plain": {"schema": {"type": "string"}}}
+          },
+          "206": {
+            "description": "The requested range of the content",
+            "headers": {
+              "Content-Range": {"schema": {"type": "string"}}
+            },
+            "content": {"text/plain": {"schema": {"type": "string"}}}
+          },
+          "403": {"$ref": "#/components/responses/Error"},
+          "404": {"$ref": "#/components/responses/Error"},
+          "416": {"description": "The range is outside of the content"},
+          "500": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/article/{id}/archive": {
+      "parameters": [
+        {"name": "id", "in": "path", "required": true, "schema": {"type": "string"}}
+      ],
+      "post": {
+        "summary": "Move an article to the archive",
+        "responses": {
+          "200": {"description": "Article archived"},
+          "404": {"$ref": "#/components/responses/Error"},
+          "409": {"$ref": "#/components/responses/Error"},
+          "500": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/article/{id}/unarchive": {
+      "parameters": [
+        {"name": "id", "in": "path", "required": true, "schema": {"type": "string"}}
+      ],
+      "post": {
+        "summary": "Restore an article from the archive",
+        "responses": {
+          "200": {"description": "Article restored"},
+          "404": {"$ref": "#/components/responses/Error"},
+          "409": {"$ref": "#/components/responses/Error"},
+          "500": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/article/{id}/touch": {
+      "parameters": [
+        {"name": "id", "in": "path", "required": true, "schema": {"type": "string"}}
+      ],
+      "post": {
+        "summary": "Bump the updated_at of an article",
+        "responses": {
+          "200": {"description": "Article touched"},
+          "404": {"$ref": "#/components/responses/Error"},
+          "500": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/article/{id}/view": {
+      "parameters": [
+        {"name": "id", "in": "path", "required": true, "schema": {"type": "string"}}
+      ],
+      "post": {
+        "summary": "Count a view of an article",
+        "responses": {
+          "200": {
+            "description": "The view count, this view included",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "type": "object",
+                  "properties": {
+                    "views": {"type": "integer"}
+                  }
+                }
+              }
+            }
+          },
+          "404": {"$ref": "#/components/responses/Error"},
+          "500": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/article/{id}/import": {
+      "parameters": [
+        {"name": "id", "in": "path", "required": true, "schema": {"type": "string"}}
+      ],
+      "post": {
+        "summary": "Replace the content of an article by the body fetched from an http or https URL",
+        "requestBody": {
+          "required": true,
+          "content": {
+            "application/json": {
+              "schema": {
+                "type": "object",
+                "properties": {
+                  "url": {"type": "string", "format": "uri"}
+                },
+                "required": ["url"]
+              }
+            }
+          }
+        },
+        "responses": {
+          "200": {"description": "Content imported"},
+          "400": {"$ref": "#/components/responses/Error"},
+          "404": {"$ref": "#/components/responses/Error"},
+          "502": {"$ref": "#/components/responses/Error"},
+          "500": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    }
+  },
+  "components": {
+    "schemas": {
+      "Article": {
+        "type": "object",
+        "properties": {
+          "id": {"type": "string", "readOnly": true},
+          "title": {"type": "string"},
+          "description": {"type": "string"},
+          "content": {"type": "string"},
+          "author": {"type": "string"},
+          "status": {"type": "string"},
+          "position": {"type": "integer", "description": "Rank given by PUT /articles/order"},
+          "updated_at": {"type": "string", "format": "date-time", "readOnly": true}
+        }
+      },
+      "Error": {
+        "type": "object",
+        "properties": {
+          "error": {
+            "type": "object",
+            "properties": {
+              "code": {"type": "string", "example": "not_found"},
+              "message": {"type": "string"},
+              "fields": {
+                "type": "object",
+                "description": "Why each invalid field was rejected, by field name",
+                "additionalProperties": {"type": "string"}
+              },
+              "detail": {"type": "string", "description": "Underlying error, when the service runs with verbose errors"}
+            },
+            "required": ["code", "message"]
+          }
+        },
+        "required": ["error"]
+      }
+    },
+    "responses": {
+      "Error": {
+        "description": "Error envelope",
+        "content": {
+          "application/json": {
+            "schema": {"$ref": "#/components/schemas/Error"}
+          }
+        }
+      }
+    }
+  }
+}
+`
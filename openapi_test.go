@@ -0,0 +1,44 @@
+package service
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+func TestOpenAPISpec(t *testing.T) {
+	rec := serve(newTestService(t).RESTful(), "GET", "/openapi.json", "")
+	var spec struct {
+		OpenAPI string                                `json:"openapi"`
+		Paths   map[string]map[string]json.RawMessage `json:"paths"`
+	}
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "application/json" {
+		t.Fatalf("got %d, Content-Type %q", rec.Code, rec.Header().Get("Content-Type"))
+	}
+	decode(t, rec, &spec)
+	if !strings.HasPrefix(spec.OpenAPI, "3.") {
+		t.Errorf("got openapi %q", spec.OpenAPI)
+	}
+
+	// Every route but the description itself and the debug routes is documented, with its methods.
+	r := mux.NewRouter()
+	newTestService(t).RegisterRoutes(r)
+	for _, route := range listRoutes(r) {
+		if route.Path == "/openapi.json" || strings.HasPrefix(route.Path, "/debug/") {
+			continue
+		}
+		ops, ok := spec.Paths[route.Path]
+		if !ok {
+			t.Errorf("%s is not documented", route.Path)
+			continue
+		}
+		for _, method := range route.Methods {
+			if _, ok := ops[strings.ToLower(method)]; !ok && method != http.MethodHead {
+				t.Errorf("%s %s is not documented", method, route.Path)
+			}
+		}
+	}
+}
@@ -0,0 +1,285 @@
+package service
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// Option configures an ArticleService created by New.
+type Option func(*ArticleService)
+
+// ErrNilDB is returned by NewChecked, and panicked with by New, when given no database.
+var ErrNilDB = errors.New("article service needs a database, got nil")
+
+// New returns an article service storing articles in db, configured by opts.
+// It panics if db is nil, so that misconfiguration shows at startup, not on the first request;
+// use NewChecked to get an error instead.
+func New(db *sql.DB, opts ...Option) *ArticleService {
+	s, err := NewChecked(db, opts...)
+	if err != nil {
+		panic(err)
+	}
+	return s
+}
+
+// NewChecked is like New, but returns ErrNilDB instead of panicking if db is nil.
+func NewChecked(db *sql.DB, opts ...Option) (*ArticleService, error) {
+	if db == nil {
+		return nil, ErrNilDB
+	}
+	s := &ArticleService{DB: db}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s, nil
+}
+
+// WithTimeout bounds how long the handling of a request may use its context, for reads and writes alike.
+// A zero duration, the default, means no timeout.
+func WithTimeout(d time.Duration) Option {
+	return func(s *ArticleService) {
+		s.timeout = d
+	}
+}
+
+// WithReadTimeout bounds GET, HEAD and OPTIONS requests, overriding WithTimeout for them.
+func WithReadTimeout(d time.Duration) Option {
+	return func(s *ArticleService) {
+		s.readTimeout = d
+	}
+}
+
+// WithWriteTimeout bounds requests of any other method, overriding WithTimeout for them.
+func WithWriteTimeout(d time.Duration) Option {
+	return func(s *ArticleService) {
+		s.writeTimeout = d
+	}
+}
+
+// WithRequestTimeout bounds the whole handling of a request, answering 503 once it is over even if the
+// handler is busy with work that ignores its context, which WithTimeout only cancels. Responses are then
+// buffered until the handler is done. A zero duration, the default, means no bound.
+func WithRequestTimeout(d time.Duration) Option {
+	return func(s *ArticleService) {
+		s.deadline = d
+	}
+}
+
+// WithAPIKey lets callers sending key in the X-API-Key header act with role.
+// Requests with a key that was not given are rejected.
+func WithAPIKey(key string, role Role) Option {
+	return func(s *ArticleService) {
+		if s.apiKeys == nil {
+			s.apiKeys = make(map[string]Role)
+		}
+		s.apiKeys[key] = role
+	}
+}
+
+// WithFieldPolicy hides article fields from callers by role, for instance the content from RoleAnonymous.
+func WithFieldPolicy(p FieldPolicy) Option {
+	return func(s *ArticleService) {
+		s.fieldPolicy = p
+	}
+}
+
+// WithLogger sets where the access log of article operations goes, slog.Default() if not set.
+func WithLogger(l *slog.Logger) Option {
+	return func(s *ArticleService) {
+		s.log = l
+	}
+}
+
+// WithDefaults fills the fields a new article leaves empty, such as its author or status, from a.
+// Fields given on create always win, and the id and timestamps of a are ignored.
+func WithDefaults(a Article) Option {
+	return func(s *ArticleService) {
+		s.defaults = a
+	}
+}
+
+// WithFieldLimits sets the maximum lengths checked when creating articles, instead of DefaultFieldLimits.
+func WithFieldLimits(l FieldLimits) Option {
+	return func(s *ArticleService) {
+		s.limits = &l
+	}
+}
+
+// WithImportAllowlist lets ImportContentFromURL fetch from hosts, by name or IP, even when they resolve
+// to loopback, private or link-local addresses, which are refused otherwise.
+func WithImportAllowlist(hosts ...string) Option {
+	return func(s *ArticleService) {
+		if s.importAllowlist == nil {
+			s.importAllowlist = make(map[string]bool)
+		}
+		for _, h := range hosts {
+			s.importAllowlist[h] = true
+		}
+	}
+}
+
+// WithDecoder lets POST /article accept bodies of mediaType, such as "application/yaml", decoded into an
+// article by d. JSON is always accepted, and other media types are answered 415.
+// FormDecoder is ready for "application/x-www-form-urlencoded".
+func WithDecoder(mediaType string, d Decoder) Option {
+	return func(s *ArticleService) {
+		if s.decoders == nil {
+			s.decoders = make(map[string]Decoder)
+		}
+		s.decoders[mediaType] = d
+	}
+}
+
+// ComputedField derives a read-only field from an article, such as an excerpt, for WithComputedField.
+// Its result is encoded as json.
+type ComputedField func(Article) interface{}
+
+// WithComputedField adds the field name, computed by f, to the articles of responses whose request asks for it
+// with ?fields=name, several names being comma separated. It is never stored. name must not be one of the
+// fields of Article, and a field policy hiding a field should also hide those computed from it.
+func WithComputedField(name string, f ComputedField) Option {
+	return func(s *ArticleService) {
+		if s.computed == nil {
+			s.computed = make(map[string]ComputedField)
+		}
+		s.computed[name] = f
+	}
+}
+
+// WithIDGenerator makes Create give articles the ids returned by newID, such as RandomUUID, rather than
+// serial ones picked by the database, so that they can't be guessed. Prepare then creates text id columns;
+// a database prepared with serial ids must be migrated before switching.
+func WithIDGenerator(newID func() string) Option {
+	return func(s *ArticleService) {
+		s.newID = newID
+	}
+}
+
+// RandomUUID returns a random, version 4, UUID, for WithIDGenerator.
+func RandomUUID() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		panic(err)
+	}
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+// WithReadOnly serves only GET, HEAD and OPTIONS requests, answering 405 to any request that could
+// change articles whatever the role of its caller.
+func WithReadOnly() Option {
+	return func(s *ArticleService) {
+		s.readOnly = true
+	}
+}
+
+// WithHSTS sends the Strict-Transport-Security header with every routed response, and redirects
+// plain http requests to https if h.Redirect. It is off by default, for local development.
+func WithHSTS(h HSTS) Option {
+	return func(s *ArticleService) {
+		s.hsts = &h
+	}
+}
+
+// WithStatementTimeout cancels any statement of the service that runs longer than d, in a transaction
+// or not, even if the client keeps waiting. Each statement is given a context done after d, so it works
+// with any driver that honors contexts, and the statement fails with context.DeadlineExceeded.
+func WithStatementTimeout(d time.Duration) Option {
+	return func(s *ArticleService) {
+		s.statementTimeout = d
+	}
+}
+
+// WithDefaultSort sorts /list responses by sort, such as "-updated_at" for the most recently updated first,
+// when the request gives no sort. It panics if sort is not one of SortColumns, optionally prefixed by "-",
+// so that misconfiguration shows at startup. Ties are broken by ascending id, as for any sort asked, keeping
+// offset pages from overlapping as long as the sorted column doesn't change between the pages being read.
+func WithDefaultSort(sort string) Option {
+	if _, err := orderBy(sort); err != nil {
+		panic(err)
+	}
+	return func(s *ArticleService) {
+		s.defaultSort = sort
+	}
+}
+
+// WithListCacheControl lets caches, such as a CDN, serve /list responses for up to d, with a
+// Cache-Control max-age in seconds. By default no Cache-Control is sent with them.
+// With API keys or a field policy, the responses also Vary on X-API-Key, as what they hold depends on the caller.
+// Single articles and the responses to writes are never to be stored.
+func WithListCacheControl(d time.Duration) Option {
+	return func(s *ArticleService) {
+		s.listMaxAge = d
+	}
+}
+
+// WithAcquireTimeout fails fast when the connection pool is exhausted: statements wait no longer than d
+// for a free connection, and are answered 503 with a Retry-After header past it, while running them is
+// still bounded only by WithTimeout. A zero duration, the default, waits as long as the request may.
+func WithAcquireTimeout(d time.Duration) Option {
+	return func(s *ArticleService) {
+		s.acquireTimeout = d
+	}
+}
+
+// WithAbsoluteURLs makes the Location and Link headers absolute URLs, built from the scheme and host
+// of the request or, behind a proxy, its X-Forwarded-Proto and X-Forwarded-Host headers.
+// They are paths relative to the host by default.
+func WithAbsoluteURLs() Option {
+	return func(s *ArticleService) {
+		s.absoluteURLs = true
+	}
+}
+
+// WithListPageLimits sets the default and maximum page sizes of /list. See PageLimits for how they combine with
+// the limit asked by the client.
+func WithListPageLimits(l PageLimits) Option {
+	return func(s *ArticleService) {
+		s.listPages = l
+	}
+}
+
+// WithSearchPageLimits is WithListPageLimits for /search, whose pages may warrant being smaller.
+func WithSearchPageLimits(l PageLimits) Option {
+	return func(s *ArticleService) {
+		s.searchPages = l
+	}
+}
+
+// WithVerboseErrors tells clients the underlying error of failed requests, such as the error of the
+// database, as the detail of the error reply. Meant for development, it is off by default; errors are
+// logged either way.
+func WithVerboseErrors(on bool) Option {
+	return func(s *ArticleService) {
+		s.verboseErrors = on
+	}
+}
+
+// WithJSONNaming names the keys of articles in replies by n, for clients expecting CamelCase.
+// Only replies change: requests and stored articles keep the SnakeCase keys.
+func WithJSONNaming(n JSONNaming) Option {
+	return func(s *ArticleService) {
+		s.jsonNaming = n
+	}
+}
+
+// WithSlowQueryThreshold logs a warning for every statement taking longer than d, with the operation
+// that ran it, the statement and its duration, but not its arguments. It is off by default.
+func WithSlowQueryThreshold(d time.Duration) Option {
+	return func(s *ArticleService) {
+		s.slowQuery = d
+	}
+}
+
+// WithLogSampling logs only about the given fraction, between 0 and 1, of successful article operations
+// in the access log. Operations answered with an error status, 4xx or 5xx, are always logged.
+func WithLogSampling(rate float64) Option {
+	return func(s *ArticleService) {
+		s.logRate = &rate
+	}
+}
@@ -0,0 +1,65 @@
+package service
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+)
+
+func TestNewWithoutDB(t *testing.T) {
+	if _, err := NewChecked(nil); !errors.Is(err, ErrNilDB) {
+		t.Errorf("got %v, want ErrNilDB", err)
+	}
+	defer func() {
+		if err, _ := recover().(error); !errors.Is(err, ErrNilDB) {
+			t.Errorf("New panicked with %v, want ErrNilDB", err)
+		}
+	}()
+	New(nil)
+}
+
+func TestAbsoluteURLs(t *testing.T) {
+	relative := serve(newTestService(t).RESTful(), "POST", "/article", `{"title":"a"}`)
+	if loc := relative.Header().Get("Location"); loc != "/article/1" {
+		t.Errorf("got Location %q by default", loc)
+	}
+	s := newTestService(t, WithAbsoluteURLs())
+	h := s.RESTful()
+	for _, tc := range []struct {
+		headers []string
+		want    string
+	}{
+		{nil, "http://example.com/article/1"},
+		{[]string{"X-Forwarded-Proto", "HTTPS", "X-Forwarded-Host", "api.example.org, proxy.internal"}, "https://api.example.org/article/2"},
+	} {
+		rec := serve(h, "POST", "/article", `{"title":"a"}`, tc.headers...)
+		if loc := rec.Header().Get("Location"); loc != tc.want {
+			t.Errorf("got Location %q, want %q", loc, tc.want)
+		}
+	}
+	rec := serve(h, "GET", "/list?limit=1", "")
+	if link := rec.Header().Get("Link"); link != `<http://example.com/list?limit=1&offset=0>; rel="first", <http://example.com/list?limit=1&offset=1>; rel="next"` {
+		t.Errorf("got Link %q", link)
+	}
+	if rec.Code != http.StatusOK {
+		t.Errorf("got %d", rec.Code)
+	}
+}
+
+func TestPageLimits(t *testing.T) {
+	for _, tc := range []struct {
+		l           PageLimits
+		asked, want int
+	}{
+		{PageLimits{}, 0, 0},
+		{PageLimits{}, 7, 7},
+		{PageLimits{Default: 10}, 0, 10},
+		{PageLimits{Default: 10}, 50, 50},
+		{PageLimits{Default: 10, Max: 20}, 50, 20},
+		{PageLimits{Max: 20}, 0, 20},
+	} {
+		if got := tc.l.limit(tc.asked); got != tc.want {
+			t.Errorf("%+v.limit(%d) = %d, want %d", tc.l, tc.asked, got, tc.want)
+		}
+	}
+}
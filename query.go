@@ -0,0 +1,193 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// QueryFilter selects and orders the articles read by Query. Zero fields don't filter.
+type QueryFilter struct {
+	Author string
+	Status string
+	// Text is looked for in the title, description and content, as by Search in its default mode,
+	// but for the fields of TextExcluded.
+	Text         string
+	TextExcluded []string
+	// UpdatedAfter and UpdatedBefore bound updated_at, inclusive and exclusive respectively.
+	UpdatedAfter  time.Time
+	UpdatedBefore time.Time
+	// Sort is one of SortColumns, prefixed by "-" for descending order, id by default.
+	Sort string
+	// Limit caps the number of articles read, after skipping Offset ones. Offset needs a Limit.
+	Limit  int
+	Offset int
+}
+
+// where builds the WHERE clause and its arguments from the set fields of f.
+func (f QueryFilter) where() (string, []interface{}) {
+	var conds []string
+	var args []interface{}
+	if f.Author != "" {
+		conds = append(conds, `author = ?`)
+		args = append(args, f.Author)
+	}
+	if f.Status != "" {
+		conds = append(conds, `status = ?`)
+		args = append(args, f.Status)
+	}
+	if f.Text != "" {
+		var alts []string
+		for _, field := range textFields(f.TextExcluded) {
+			alts = append(alts, field+` LIKE ? ESCAPE '!'`)
+			args = append(args, "%"+escapeLike(f.Text)+"%")
+		}
+		if len(alts) == 0 {
+			alts = []string{`1 = 0`}
+		}
+		conds = append(conds, `(`+strings.Join(alts, ` OR `)+`)`)
+	}
+	if !f.UpdatedAfter.IsZero() {
+		conds = append(conds, `updated_at >= ?`)
+		args = append(args, f.UpdatedAfter.UTC())
+	}
+	if !f.UpdatedBefore.IsZero() {
+		conds = append(conds, `updated_at < ?`)
+		args = append(args, f.UpdatedBefore.UTC())
+	}
+	if len(conds) == 0 {
+		return "", nil
+	}
+	return `WHERE ` + strings.Join(conds, ` AND `) + ` `, args
+}
+
+// statement builds the SELECT of columns from the articles selected by f, in order.
+func (f QueryFilter) statement(columns string) (string, []interface{}, error) {
+	if f.Sort == "" {
+		f.Sort = "id"
+	}
+	order, err := orderBy(f.Sort)
+	if err != nil {
+		return "", nil, err
+	}
+	where, args := f.where()
+	stat := `SELECT ` + columns + ` FROM articles ` + where + order
+	if f.Limit > 0 {
+		stat += ` LIMIT ? OFFSET ?`
+		args = append(args, f.Limit, f.Offset)
+	}
+	return stat + `;`, args, nil
+}
+
+// Query reads the articles selected by f, combining all its set fields.
+// It fails with ErrInvalidSort if f.Sort is not one of SortColumns.
+func (s *ArticleService) Query(ctx context.Context, f QueryFilter) ([]Article, error) {
+	stat, args, err := f.statement(articleColumns)
+	if err != nil {
+		return nil, err
+	}
+	if s.DB == nil {
+		panic("no existing database")
+	}
+	rows, err := s.db().QueryContext(ctx, stat, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ret := make([]Article, 0, 20)
+	for rows.Next() {
+		var article Article
+		if err := scanArticle(rows, &article); err != nil {
+			return nil, err
+		}
+		ret = append(ret, article)
+	}
+	return ret, rows.Err()
+}
+
+// QueryCount counts the articles Query would read for f, regardless of its Limit and Offset.
+func (s *ArticleService) QueryCount(ctx context.Context, f QueryFilter) (int, error) {
+	where, args := f.where()
+	stat := `SELECT COUNT(*) FROM articles ` + where + `;`
+	if s.DB == nil {
+		panic("no existing database")
+	}
+	var n int
+	err := s.db().QueryRowContext(ctx, stat, args...).Scan(&n)
+	return n, err
+}
+
+// ListByAuthor reads a page of limit articles by author, after skipping offset ones, in id order.
+// A zero limit reads all of them.
+func (s *ArticleService) ListByAuthor(ctx context.Context, author string, limit, offset int) ([]Article, error) {
+	return s.Query(ctx, QueryFilter{Author: author, Limit: limit, Offset: offset})
+}
+
+// ListIDs reads only the ids of the articles Query would read for f, in the same order.
+func (s *ArticleService) ListIDs(ctx context.Context, f QueryFilter) ([]string, error) {
+	stat, args, err := f.statement(`id`)
+	if err != nil {
+		return nil, err
+	}
+	if s.DB == nil {
+		panic("no existing database")
+	}
+	rows, err := s.db().QueryContext(ctx, stat, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ret := make([]string, 0, 20)
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ret = append(ret, id)
+	}
+	return ret, rows.Err()
+}
+
+// queryFilter reads the filter of a /list request from the query parameters of r:
+// author, status, q, matched only against the fields the caller may see, updated_after and updated_before in RFC 3339, sort, limit and offset.
+// Without sort, the default sort given by WithDefaultSort applies. An invalid sort fails here, before anything is read.
+func (s *ArticleService) queryFilter(r *http.Request) (QueryFilter, error) {
+	q := r.URL.Query()
+	f := QueryFilter{
+		Author:       q.Get("author"),
+		Status:       q.Get("status"),
+		Text:         q.Get("q"),
+		Sort:         q.Get("sort"),
+		TextExcluded: s.fieldPolicy[roleFrom(r.Context())],
+	}
+	if f.Sort == "" {
+		f.Sort = s.defaultSort
+	}
+	if f.Sort != "" {
+		if _, err := orderBy(f.Sort); err != nil {
+			return QueryFilter{}, err
+		}
+	}
+	dates := []struct {
+		name string
+		t    *time.Time
+	}{{"updated_after", &f.UpdatedAfter}, {"updated_before", &f.UpdatedBefore}}
+	var err error
+	for _, d := range dates {
+		v := q.Get(d.name)
+		if v == "" {
+			continue
+		}
+		if *d.t, err = time.Parse(time.RFC3339, v); err != nil {
+			return QueryFilter{}, fmt.Errorf("%s must be an RFC 3339 date, got %q", d.name, v)
+		}
+	}
+	if f.Limit, f.Offset, err = pageParams(r); err != nil {
+		return QueryFilter{}, err
+	}
+	return f, nil
+}
@@ -0,0 +1,175 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// seedQuery creates articles by various authors and statuses, updated an hour apart from the
+// returned time onwards, in id order.
+func seedQuery(t *testing.T, s *ArticleService) time.Time {
+	t.Helper()
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i, a := range []Article{
+		{Title: "go basics", Author: "ann", Status: "published"},
+		{Title: "go generics", Author: "ann", Status: "draft"},
+		{Title: "rust basics", Author: "bob", Status: "published"},
+		{Title: "snake_case names", Author: "ann", Status: "published"},
+	} {
+		a.ID = string(rune('1' + i))
+		a.UpdatedAt = start.Add(time.Duration(i) * time.Hour)
+		if _, err := s.DB.Exec(`INSERT INTO articles (`+articleColumns+`) VALUES (?, ?, ?, ?, ?, ?, ?, ?);`,
+			a.ID, a.Title, a.Desc, a.Content, a.Author, a.Status, a.Position, a.UpdatedAt); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return start
+}
+
+func TestQuery(t *testing.T) {
+	s := newTestService(t)
+	ctx := context.Background()
+	start := seedQuery(t, s)
+	for _, tc := range []struct {
+		f    QueryFilter
+		want []string
+	}{
+		{QueryFilter{}, []string{"1", "2", "3", "4"}},
+		{QueryFilter{Author: "ann"}, []string{"1", "2", "4"}},
+		{QueryFilter{Author: "ann", Status: "published"}, []string{"1", "4"}},
+		{QueryFilter{Author: "ann", Text: "go"}, []string{"1", "2"}},
+		{QueryFilter{Text: "basics", Status: "published"}, []string{"1", "3"}},
+		{QueryFilter{Text: "_"}, []string{"4"}},
+		{QueryFilter{Text: "%"}, nil},
+		{QueryFilter{UpdatedAfter: start.Add(time.Hour)}, []string{"2", "3", "4"}},
+		{QueryFilter{UpdatedAfter: start.Add(time.Hour), UpdatedBefore: start.Add(3 * time.Hour)}, []string{"2", "3"}},
+		{QueryFilter{Author: "ann", Sort: "-updated_at"}, []string{"4", "2", "1"}},
+		{QueryFilter{Author: "ann", Limit: 2, Offset: 1}, []string{"2", "4"}},
+		{QueryFilter{Author: "nobody"}, nil},
+	} {
+		articles, err := s.Query(ctx, tc.f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := idsOf(articles); len(got) != len(tc.want) || len(got) > 0 && !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%+v: got %v, want %v", tc.f, got, tc.want)
+		}
+		ids, err := s.ListIDs(ctx, tc.f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(ids, idsOf(articles)) {
+			t.Errorf("%+v: ListIDs got %v, Query %v", tc.f, ids, idsOf(articles))
+		}
+		f := tc.f
+		f.Limit, f.Offset = 0, 0
+		all, _ := s.Query(ctx, f)
+		if n, err := s.QueryCount(ctx, tc.f); err != nil || n != len(all) {
+			t.Errorf("%+v: counted %d, %v, want %d", tc.f, n, err, len(all))
+		}
+	}
+	if _, err := s.Query(ctx, QueryFilter{Sort: "title"}); !errors.Is(err, ErrInvalidSort) {
+		t.Errorf("got %v, want ErrInvalidSort", err)
+	}
+}
+
+func TestListByAuthor(t *testing.T) {
+	s := newTestService(t)
+	seedQuery(t, s)
+	for _, tc := range []struct {
+		limit, offset int
+		want          []string
+	}{{0, 0, []string{"1", "2", "4"}}, {2, 0, []string{"1", "2"}}, {2, 2, []string{"4"}}} {
+		articles, err := s.ListByAuthor(context.Background(), "ann", tc.limit, tc.offset)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(idsOf(articles), tc.want) {
+			t.Errorf("limit %d, offset %d: got %v, want %v", tc.limit, tc.offset, idsOf(articles), tc.want)
+		}
+	}
+}
+
+func TestListRouteFilter(t *testing.T) {
+	s := newTestService(t)
+	h := s.RESTful()
+	seedQuery(t, s)
+	for target, want := range map[string][]string{
+		"/list?author=ann&status=published":                          {"1", "4"},
+		"/list?q=go&sort=-id":                                        {"2", "1"},
+		"/list?updated_after=2024-01-01T01:00:00Z":                   {"2", "3", "4"},
+		"/list?updated_before=2024-01-01T01:00:00Z":                  {"1"},
+		"/list?updated_after=2024-01-01T00:30:00%2B00:00&author=ann": {"2", "4"},
+	} {
+		var articles []Article
+		decode(t, serve(h, "GET", target, ""), &articles)
+		if !reflect.DeepEqual(idsOf(articles), want) {
+			t.Errorf("%s got %v, want %v", target, idsOf(articles), want)
+		}
+		var ids []string
+		decode(t, serve(h, "GET", "/list/ids"+target[len("/list"):], ""), &ids)
+		if !reflect.DeepEqual(ids, want) {
+			t.Errorf("/list/ids of %s got %v, want %v", target, ids, want)
+		}
+	}
+	for _, target := range []string{"/list?updated_after=2024-01-01", "/list/ids?sort=title", "/list/ids?limit=-1"} {
+		if rec := serve(h, "GET", target, ""); rec.Code != http.StatusBadRequest {
+			t.Errorf("%s got %d, want 400", target, rec.Code)
+		}
+	}
+}
+
+func TestDefaultSort(t *testing.T) {
+	s := newTestService(t, WithDefaultSort("-updated_at"))
+	h := s.RESTful()
+	seedQuery(t, s)
+	for target, want := range map[string][]string{
+		"/list":            {"4", "3", "2", "1"},
+		"/list?sort=id":    {"1", "2", "3", "4"},
+		"/list?author=bob": {"3"},
+	} {
+		var articles []Article
+		decode(t, serve(h, "GET", target, ""), &articles)
+		if !reflect.DeepEqual(idsOf(articles), want) {
+			t.Errorf("%s got %v, want %v", target, idsOf(articles), want)
+		}
+	}
+	var ids []string
+	decode(t, serve(h, "GET", "/list/ids", ""), &ids)
+	if want := []string{"4", "3", "2", "1"}; !reflect.DeepEqual(ids, want) {
+		t.Errorf("/list/ids got %v, want %v", ids, want)
+	}
+	// Query itself keeps sorting by id.
+	articles, _ := s.Query(context.Background(), QueryFilter{})
+	if want := []string{"1", "2", "3", "4"}; !reflect.DeepEqual(idsOf(articles), want) {
+		t.Errorf("Query got %v, want %v", idsOf(articles), want)
+	}
+
+	// Articles updated at once are paged by id, so pages never overlap.
+	if _, err := s.DB.Exec(`UPDATE articles SET updated_at = ?;`, time.Now().UTC()); err != nil {
+		t.Fatal(err)
+	}
+	for target, want := range map[string][]string{
+		"/list?limit=2":          {"1", "2"},
+		"/list?limit=2&offset=2": {"3", "4"},
+	} {
+		var articles []Article
+		decode(t, serve(h, "GET", target, ""), &articles)
+		if !reflect.DeepEqual(idsOf(articles), want) {
+			t.Errorf("%s with ties got %v, want %v", target, idsOf(articles), want)
+		}
+	}
+}
+
+func TestDefaultSortIsChecked(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Errorf("invalid default sort didn't panic")
+		}
+	}()
+	WithDefaultSort("title")
+}
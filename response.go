@@ -0,0 +1,155 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// apiError is the body of every error response, wrapped as {"error": {...}}.
+type apiError struct {
+	Code    string            `json:"code"`
+	Message string            `json:"message"`
+	Fields  map[string]string `json:"fields,omitempty"`
+	// Detail is the underlying error, only given out with WithVerboseErrors.
+	Detail string `json:"detail,omitempty"`
+}
+
+// JSONNaming is how the keys of articles are named in replies, set by WithJSONNaming.
+type JSONNaming int
+
+// JSON namings of article keys. Requests are always read with SnakeCase keys.
+const (
+	// SnakeCase names keys as the json tags of Article, such as updated_at. It is the default.
+	SnakeCase JSONNaming = iota
+	// CamelCase names keys such as updatedAt.
+	CamelCase
+)
+
+// rename returns m with its keys named by n.
+func (n JSONNaming) rename(m map[string]json.RawMessage) map[string]json.RawMessage {
+	if n != CamelCase || m == nil {
+		return m
+	}
+	renamed := make(map[string]json.RawMessage, len(m))
+	for k, v := range m {
+		parts := strings.Split(k, "_")
+		for i := 1; i < len(parts); i++ {
+			if parts[i] != "" {
+				parts[i] = strings.ToUpper(parts[i][:1]) + parts[i][1:]
+			}
+		}
+		renamed[strings.Join(parts, "")] = v
+	}
+	return renamed
+}
+
+// writeJSON replies to r with status and v as json, indented when r asks for ?pretty=true.
+// Lists are always encoded as arrays, [] when empty, never null.
+// v is encoded before anything is written, so an encoding failure still gets a proper error reply.
+// Failures are logged, including writes that fail once the status is sent, as when the client went away.
+func (s *ArticleService) writeJSON(w http.ResponseWriter, r *http.Request, status int, v interface{}) {
+	b := &bytes.Buffer{}
+	enc := json.NewEncoder(b)
+	if pretty, _ := strconv.ParseBool(r.URL.Query().Get("pretty")); pretty {
+		enc.SetIndent("", "  ")
+	}
+	if err := enc.Encode(nonNilSlice(v)); err != nil {
+		s.logger().Error("could not encode reply", slog.String("method", r.Method), slog.String("path", r.URL.Path), slog.Any("error", err))
+		writeError(w, http.StatusInternalServerError, "could not encode json")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Length", strconv.Itoa(b.Len()))
+	w.WriteHeader(status)
+	if _, err := b.WriteTo(w); err != nil {
+		s.logger().Warn("could not write reply", slog.String("method", r.Method), slog.String("path", r.URL.Path), slog.Any("error", err))
+	}
+}
+
+// nonNilSlice returns v, or an empty slice of the same type if v is a nil slice.
+func nonNilSlice(v interface{}) interface{} {
+	if rv := reflect.ValueOf(v); rv.Kind() == reflect.Slice && rv.IsNil() {
+		return reflect.MakeSlice(rv.Type(), 0, 0).Interface()
+	}
+	return v
+}
+
+// writeError replies to the request with the json error envelope.
+// Its code is derived from status, for instance not_found for 404.
+func writeError(w http.ResponseWriter, status int, msg string) {
+	writeAPIError(w, status, apiError{Code: errorCode(status), Message: msg})
+}
+
+// writeValidationError replies 400 with the invalid fields of err in the error envelope.
+func writeValidationError(w http.ResponseWriter, err *ValidationError) {
+	writeAPIError(w, http.StatusBadRequest, apiError{Code: "invalid_article", Message: err.Error(), Fields: err.Fields})
+}
+
+// retryAfter is how many seconds clients are told to wait when the connection pool is exhausted.
+const retryAfter = 1
+
+// statusClientClosedRequest is the non-standard status, used by nginx, of requests whose client went away.
+const statusClientClosedRequest = 499
+
+// writeStoreError replies 500 with msg for err returned by the store, or 503 with a Retry-After header
+// when err comes from waiting in vain for a connection of an exhausted pool, so that clients back off.
+// Requests whose context ended are answered 499 if canceled, as when the client went away, and 503 if
+// timed out; database/sql checks the context before taking a connection, so these cost no query.
+// err is logged, and only told to the client as the detail of the error if WithVerboseErrors is on.
+func (s *ArticleService) writeStoreError(w http.ResponseWriter, err error, msg string) {
+	status := http.StatusInternalServerError
+	switch {
+	case s.poolExhausted(err):
+		w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+		status, msg = http.StatusServiceUnavailable, "too many concurrent requests"
+	case errors.Is(err, context.Canceled):
+		status, msg = statusClientClosedRequest, "client closed request"
+	case errors.Is(err, context.DeadlineExceeded):
+		status, msg = http.StatusServiceUnavailable, "request timed out"
+	}
+	s.logger().Error(msg, slog.Int("status", status), slog.Any("error", err))
+	e := apiError{Code: errorCode(status), Message: msg}
+	if s.verboseErrors {
+		e.Detail = err.Error()
+	}
+	writeAPIError(w, status, e)
+}
+
+// poolExhausted tells whether err is a deadline exceeded while every connection allowed by
+// SetMaxOpenConns is in use. database/sql reports both the same way, so the pool is looked at.
+func (s *ArticleService) poolExhausted(err error) bool {
+	if !errors.Is(err, context.DeadlineExceeded) || s.DB == nil {
+		return false
+	}
+	st := s.DB.Stats()
+	return st.MaxOpenConnections > 0 && st.InUse >= st.MaxOpenConnections
+}
+
+func writeAPIError(w http.ResponseWriter, status int, e apiError) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(struct {
+		Error apiError `json:"error"`
+	}{e})
+}
+
+// errorCode turns the text of status into a code, such as method_not_allowed.
+func errorCode(status int) string {
+	if status == statusClientClosedRequest {
+		return "client_closed_request"
+	}
+	return strings.Map(func(r rune) rune {
+		if r == ' ' || r == '-' {
+			return '_'
+		}
+		return r
+	}, strings.ToLower(http.StatusText(status)))
+}
@@ -0,0 +1,159 @@
+package service
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWriteJSON(t *testing.T) {
+	s := newTestService(t)
+	for _, tc := range []struct {
+		target string
+		v      interface{}
+		want   string
+	}{
+		{"/", map[string]int{"a": 1}, "{\"a\":1}\n"},
+		{"/?pretty=true", map[string]int{"a": 1}, "{\n  \"a\": 1\n}\n"},
+		{"/", []Article(nil), "[]\n"},
+		{"/", []string(nil), "[]\n"},
+	} {
+		rec := httptest.NewRecorder()
+		s.writeJSON(rec, httptest.NewRequest("GET", tc.target, nil), http.StatusOK, tc.v)
+		if rec.Body.String() != tc.want || rec.Header().Get("Content-Length") != strconv.Itoa(len(tc.want)) {
+			t.Errorf("%s %#v: got %q, Content-Length %q", tc.target, tc.v, rec.Body, rec.Header().Get("Content-Length"))
+		}
+	}
+}
+
+func TestWriteJSONEncodingFailure(t *testing.T) {
+	logTo, logs := withTestLogger()
+	rec := httptest.NewRecorder()
+	newTestService(t, logTo).writeJSON(rec, httptest.NewRequest("GET", "/", nil), http.StatusOK, map[string]interface{}{"f": func() {}})
+	if rec.Code != http.StatusInternalServerError || errorOf(t, rec).Message != "could not encode json" {
+		t.Errorf("got %d %s", rec.Code, rec.Body)
+	}
+	records := logs.recordsOf(t, "could not encode reply")
+	if len(records) != 1 || records[0]["path"] != "/" || !strings.Contains(records[0]["error"].(string), "unsupported type") {
+		t.Errorf("got %v", records)
+	}
+}
+
+func TestErrorCode(t *testing.T) {
+	for status, want := range map[int]string{
+		http.StatusNotFound:             "not_found",
+		http.StatusMethodNotAllowed:     "method_not_allowed",
+		http.StatusMultiStatus:          "multi_status",
+		http.StatusNonAuthoritativeInfo: "non_authoritative_information",
+		statusClientClosedRequest:       "client_closed_request",
+	} {
+		if got := errorCode(status); got != want {
+			t.Errorf("errorCode(%d) = %q, want %q", status, got, want)
+		}
+	}
+}
+
+func TestVerboseErrors(t *testing.T) {
+	for _, verbose := range []bool{false, true} {
+		s := newTestService(t, WithVerboseErrors(verbose))
+		if _, err := s.DB.Exec(`DROP TABLE articles;`); err != nil {
+			t.Fatal(err)
+		}
+		rec := serve(s.RESTful(), "GET", "/list", "")
+		e := errorOf(t, rec)
+		if rec.Code != http.StatusInternalServerError || e.Message != "could not read data" {
+			t.Errorf("got %d %+v", rec.Code, e)
+		}
+		if hasDetail := strings.Contains(e.Detail, "no such table"); hasDetail != verbose {
+			t.Errorf("verbose %t: got detail %q", verbose, e.Detail)
+		}
+	}
+}
+
+func TestStoreErrorsAreLogged(t *testing.T) {
+	logTo, logs := withTestLogger()
+	s := newTestService(t, logTo)
+	s.DB.Exec(`DROP TABLE articles;`)
+	serve(s.RESTful(), "GET", "/article/1", "")
+	records := logs.recordsOf(t, "could not read data")
+	if len(records) != 1 || !strings.Contains(records[0]["error"].(string), "no such table") || records[0]["status"] != float64(500) {
+		t.Errorf("got %v", records)
+	}
+}
+
+func TestPoolExhausted(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		opts []Option
+	}{
+		{"request timeout", []Option{WithTimeout(30 * time.Millisecond)}},
+		{"acquire timeout", []Option{WithAcquireTimeout(30 * time.Millisecond)}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			s := newTestService(t, tc.opts...)
+			holdConn(t, s)
+			rec := serve(s.RESTful(), "GET", "/article/1", "")
+			if rec.Code != http.StatusServiceUnavailable || rec.Header().Get("Retry-After") != "1" {
+				t.Fatalf("got %d, Retry-After %q", rec.Code, rec.Header().Get("Retry-After"))
+			}
+			if e := errorOf(t, rec); e.Message != "too many concurrent requests" {
+				t.Errorf("got %+v", e)
+			}
+		})
+	}
+}
+
+func TestAcquireTimeoutOnlyBoundsWaiting(t *testing.T) {
+	s := newTestService(t, WithAcquireTimeout(200*time.Millisecond))
+	mustCreate(t, s, Article{Title: "a"})
+	release := holdConn(t, s)
+	time.AfterFunc(20*time.Millisecond, release)
+	if rec := serve(s.RESTful(), "GET", "/article/1", ""); rec.Code != http.StatusOK {
+		t.Errorf("got %d %s once the connection was free", rec.Code, rec.Body)
+	}
+}
+
+func TestCanceledRequest(t *testing.T) {
+	db, recording := openRecording(t)
+	logTo, _ := withTestLogger()
+	h := New(db, logTo).RESTful()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	req := httptest.NewRequest("GET", "/article/1", nil).WithContext(ctx)
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != statusClientClosedRequest || errorOf(t, rec).Code != "client_closed_request" {
+		t.Errorf("got %d %s", rec.Code, rec.Body)
+	}
+	if st := recording.recorded(); len(st) != 0 {
+		t.Errorf("ran %+v for a canceled request", st)
+	}
+	// Whereas the same request, not canceled, reaches the database.
+	serve(h, "GET", "/article/1", "")
+	if st := recording.recorded(); len(st) != 1 {
+		t.Errorf("ran %+v, want the statement of Get", st)
+	}
+}
+
+func TestJSONNaming(t *testing.T) {
+	s := newTestService(t, WithJSONNaming(CamelCase))
+	h := s.RESTful()
+	rec := serve(h, "POST", "/article", `{"title":"a","description":"d"}`)
+	var created map[string]interface{}
+	decode(t, rec, &created)
+	if _, ok := created["updatedAt"]; !ok || created["description"] != "d" {
+		t.Errorf("got %v", created)
+	}
+	if _, ok := created["updated_at"]; ok {
+		t.Errorf("got snake case key in %v", created)
+	}
+	var listed []map[string]interface{}
+	decode(t, serve(h, "GET", "/list", ""), &listed)
+	if _, ok := listed[0]["updatedAt"]; len(listed) != 1 || !ok {
+		t.Errorf("got %v", listed)
+	}
+}
@@ -0,0 +1,55 @@
+package service
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// readOnlyFields are the json fields of Article set by the service, whatever clients send.
+var readOnlyFields = map[string]bool{"id": true, "updated_at": true}
+
+// ArticleSchema returns a JSON Schema, draft 2020-12, of articles as the service reads and writes them.
+// It is derived from the json tags and types of Article, with the field limits in force as maxLength
+// and keys named as set by WithJSONNaming, so it can't drift from either.
+// No field is required, as Create fills in the missing ones.
+func (s *ArticleService) ArticleSchema() map[string]interface{} {
+	l := DefaultFieldLimits
+	if s.limits != nil {
+		l = *s.limits
+	}
+	maxLength := map[string]int{"title": l.Title, "description": l.Desc, "content": l.Content}
+
+	props := make(map[string]json.RawMessage)
+	t := reflect.TypeOf(Article{})
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name := strings.Split(f.Tag.Get("json"), ",")[0]
+		if name == "" || name == "-" {
+			continue
+		}
+		prop := make(map[string]interface{})
+		switch {
+		case f.Type == reflect.TypeOf(time.Time{}):
+			prop["type"], prop["format"] = "string", "date-time"
+		case f.Type.Kind() == reflect.String:
+			prop["type"] = "string"
+		case f.Type.Kind() == reflect.Int:
+			prop["type"] = "integer"
+		}
+		if max := maxLength[name]; max > 0 {
+			prop["maxLength"] = max
+		}
+		if readOnlyFields[name] {
+			prop["readOnly"] = true
+		}
+		props[name], _ = json.Marshal(prop)
+	}
+	return map[string]interface{}{
+		"$schema":    "https://json-schema.org/draft/2020-12/schema",
+		"title":      "Article",
+		"type":       "object",
+		"properties": s.jsonNaming.rename(props),
+	}
+}
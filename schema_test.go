@@ -0,0 +1,56 @@
+package service
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestArticleSchema(t *testing.T) {
+	s := newTestService(t, WithFieldLimits(FieldLimits{Title: 10}))
+	var schema struct {
+		Schema     string                            `json:"$schema"`
+		Type       string                            `json:"type"`
+		Properties map[string]map[string]interface{} `json:"properties"`
+	}
+	decode(t, serve(s.RESTful(), "GET", "/schema/article", ""), &schema)
+	if schema.Schema != "https://json-schema.org/draft/2020-12/schema" || schema.Type != "object" {
+		t.Errorf("got %+v", schema)
+	}
+	want := map[string]map[string]interface{}{
+		"id":          {"type": "string", "readOnly": true},
+		"title":       {"type": "string", "maxLength": float64(10)},
+		"description": {"type": "string"},
+		"content":     {"type": "string"},
+		"author":      {"type": "string"},
+		"status":      {"type": "string"},
+		"position":    {"type": "integer"},
+		"updated_at":  {"type": "string", "format": "date-time", "readOnly": true},
+	}
+	if !reflect.DeepEqual(schema.Properties, want) {
+		t.Errorf("got properties %v, want %v", schema.Properties, want)
+	}
+}
+
+func TestArticleSchemaMatchesArticles(t *testing.T) {
+	s := newTestService(t)
+	b, err := json.Marshal(Article{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var fields map[string]interface{}
+	json.Unmarshal(b, &fields)
+	props := s.ArticleSchema()["properties"].(map[string]json.RawMessage)
+	if len(props) != len(fields) {
+		t.Errorf("schema has %d properties, articles %d fields", len(props), len(fields))
+	}
+	for name := range fields {
+		if _, ok := props[name]; !ok {
+			t.Errorf("schema lacks %s", name)
+		}
+	}
+	camel := newTestService(t, WithJSONNaming(CamelCase)).ArticleSchema()["properties"].(map[string]json.RawMessage)
+	if _, ok := camel["updatedAt"]; !ok {
+		t.Errorf("camel case schema lacks updatedAt")
+	}
+}
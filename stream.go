@@ -0,0 +1,41 @@
+package service
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// ndjsonFlushEvery is how many lines an ndjsonWriter writes between flushes.
+const ndjsonFlushEvery = 20
+
+// ndjsonWriter streams values to a response as newline delimited json, flushing every few lines
+// so clients get results while the rest are still being read.
+type ndjsonWriter struct {
+	w   http.ResponseWriter
+	enc *json.Encoder
+	n   int
+}
+
+func newNDJSONWriter(w http.ResponseWriter) *ndjsonWriter {
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	return &ndjsonWriter{w: w, enc: json.NewEncoder(w)}
+}
+
+// Write writes v as one line.
+func (nw *ndjsonWriter) Write(v interface{}) error {
+	if err := nw.enc.Encode(v); err != nil {
+		return err
+	}
+	nw.n++
+	if nw.n%ndjsonFlushEvery == 0 {
+		nw.Flush()
+	}
+	return nil
+}
+
+// Flush sends the lines written so far to the client.
+func (nw *ndjsonWriter) Flush() {
+	if f, ok := nw.w.(http.Flusher); ok {
+		f.Flush()
+	}
+}
@@ -0,0 +1,74 @@
+package service
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestSearchStream(t *testing.T) {
+	s := newTestService(t)
+	h := s.RESTful()
+	n := 2*ndjsonFlushEvery + 3
+	for i := 0; i < n; i++ {
+		mustCreate(t, s, Article{Title: "match"})
+	}
+	mustCreate(t, s, Article{Title: "other"})
+
+	rec := serve(h, "GET", "/search/stream?q=match", "")
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "application/x-ndjson" {
+		t.Fatalf("got %d, Content-Type %q", rec.Code, rec.Header().Get("Content-Type"))
+	}
+	lines := 0
+	sc := bufio.NewScanner(rec.Body)
+	for sc.Scan() {
+		var a Article
+		if err := json.Unmarshal(sc.Bytes(), &a); err != nil {
+			t.Fatalf("line %d is not an article: %q", lines, sc.Text())
+		}
+		if a.Title != "match" {
+			t.Errorf("streamed %+v", a)
+		}
+		lines++
+	}
+	if lines != n {
+		t.Errorf("streamed %d lines, want %d", lines, n)
+	}
+	if !rec.Flushed {
+		t.Errorf("stream never flushed")
+	}
+	if rec := serve(h, "GET", "/search/stream?q=nothing", ""); rec.Code != http.StatusOK || rec.Body.Len() != 0 {
+		t.Errorf("no match got %d %q", rec.Code, rec.Body)
+	}
+	if rec := serve(h, "GET", "/search/stream?q=a&mode=fuzzy", ""); rec.Code != http.StatusBadRequest {
+		t.Errorf("unknown mode got %d, want 400", rec.Code)
+	}
+}
+
+func TestSearchStreamFailsBeforeStreaming(t *testing.T) {
+	s := newTestService(t)
+	s.DB.Exec(`DROP TABLE articles;`)
+	rec := serve(s.RESTful(), "GET", "/search/stream?q=a", "")
+	if rec.Code != http.StatusInternalServerError || !strings.Contains(rec.Header().Get("Content-Type"), "json") {
+		t.Errorf("got %d, Content-Type %q", rec.Code, rec.Header().Get("Content-Type"))
+	}
+}
+
+func TestSearchStreamStoppedIsLogged(t *testing.T) {
+	logTo, logs := withTestLogger()
+	s := newTestService(t, logTo)
+	mustCreate(t, s, Article{Title: "match"})
+	// A row that can't be read, after one already streamed.
+	if _, err := s.DB.Exec(`INSERT INTO articles (` + articleColumns + `) VALUES (7, NULL, 'match', '', '', '', 0, '2024-01-01');`); err != nil {
+		t.Fatal(err)
+	}
+	rec := serve(s.RESTful(), "GET", "/search/stream?q=match", "")
+	if rec.Code != http.StatusOK || strings.Count(rec.Body.String(), "\n") != 1 {
+		t.Errorf("got %d %q, want the first article only", rec.Code, rec.Body)
+	}
+	if records := logs.recordsOf(t, "search stream stopped"); len(records) != 1 || records[0]["error"] == nil {
+		t.Errorf("got %v", records)
+	}
+}
@@ -0,0 +1,69 @@
+package service
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// FieldLimits are the most characters each article field may hold, zero meaning no limit.
+// They catch values the database columns would reject with an opaque error.
+type FieldLimits struct {
+	Title   int
+	Desc    int
+	Content int
+}
+
+// DefaultFieldLimits are the limits used unless WithFieldLimits sets others.
+var DefaultFieldLimits = FieldLimits{
+	Title:   255,
+	Desc:    1024,
+	Content: 1 << 20,
+}
+
+// ValidationError tells which fields of an article are invalid, by json name, and why.
+type ValidationError struct {
+	Fields map[string]string
+}
+
+func (e *ValidationError) Error() string {
+	names := make([]string, 0, len(e.Fields))
+	for name := range e.Fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	msgs := make([]string, len(names))
+	for i, name := range names {
+		msgs[i] = name + ": " + e.Fields[name]
+	}
+	return "invalid article: " + strings.Join(msgs, "; ")
+}
+
+// Normalize returns a with its title trimmed and its runs of whitespace collapsed into single spaces.
+// Normalizing twice changes nothing more.
+func (a Article) Normalize() Article {
+	a.Title = strings.Join(strings.Fields(a.Title), " ")
+	return a
+}
+
+// Validate checks a against DefaultFieldLimits, returning a *ValidationError for the fields that exceed them.
+func (a Article) Validate() error {
+	return a.validate(DefaultFieldLimits)
+}
+
+func (a Article) validate(l FieldLimits) error {
+	fields := make(map[string]string)
+	check := func(name, v string, max int) {
+		if max > 0 && utf8.RuneCountInString(v) > max {
+			fields[name] = fmt.Sprintf("must be at most %d characters", max)
+		}
+	}
+	check("title", a.Title, l.Title)
+	check("description", a.Desc, l.Desc)
+	check("content", a.Content, l.Content)
+	if len(fields) > 0 {
+		return &ValidationError{Fields: fields}
+	}
+	return nil
+}
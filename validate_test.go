@@ -0,0 +1,55 @@
+package service
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	if err := (Article{Title: strings.Repeat("é", DefaultFieldLimits.Title)}).Validate(); err != nil {
+		t.Errorf("title of as many characters as allowed got %v", err)
+	}
+	err := Article{
+		Title:   strings.Repeat("a", DefaultFieldLimits.Title+1),
+		Desc:    strings.Repeat("a", DefaultFieldLimits.Desc+1),
+		Content: "short",
+	}.Validate()
+	var invalid *ValidationError
+	if !errors.As(err, &invalid) {
+		t.Fatalf("got %v, want a ValidationError", err)
+	}
+	if len(invalid.Fields) != 2 || invalid.Fields["title"] != "must be at most 255 characters" || invalid.Fields["description"] == "" {
+		t.Errorf("got fields %v", invalid.Fields)
+	}
+	if want := "invalid article: description: must be at most 1024 characters; title: must be at most 255 characters"; err.Error() != want {
+		t.Errorf("got %q, want %q", err, want)
+	}
+}
+
+func TestFieldLimits(t *testing.T) {
+	l := FieldLimits{Title: 2, Content: 0}
+	if err := (Article{Title: "ab", Content: strings.Repeat("a", DefaultFieldLimits.Content+1)}).validate(l); err != nil {
+		t.Errorf("got %v, want no limit on content", err)
+	}
+	if err := (Article{Title: "abc"}).validate(l); err == nil {
+		t.Errorf("title over its limit passed")
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	for in, want := range map[string]string{
+		"  spaced  ":         "spaced",
+		"a \t b\n\nc":        "a b c",
+		"already normalized": "already normalized",
+		"   ":                "",
+	} {
+		got := Article{Title: in, Content: " untouched "}.Normalize()
+		if got.Title != want || got.Content != " untouched " {
+			t.Errorf("%q normalized into %+v, want title %q", in, got, want)
+		}
+		if again := got.Normalize(); again != got {
+			t.Errorf("normalizing %q twice got %+v", in, again)
+		}
+	}
+}